@@ -0,0 +1,180 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// bountyHunter is the free-for-all mode: every player is its own team,
+// every kill scores a point and raises the killer's bounty by the kill
+// reward of the weapon used. Whoever takes down a wanted player cashes in
+// the whole bounty. First to the score limit, or best score when time runs
+// out, wins.
+type bountyHunter struct {
+	baseMode
+
+	scoreLimit   int
+	timeLimit    time.Duration
+	respawnDelay time.Duration
+
+	bounties map[*actor.PID]int
+}
+
+func newBountyHunter(config MatchConfig) *bountyHunter {
+	return &bountyHunter{
+		scoreLimit:   config.BountyScoreLimit,
+		timeLimit:    config.BountyTimeLimit,
+		respawnDelay: config.RespawnDelay,
+		bounties:     make(map[*actor.PID]int),
+	}
+}
+
+func (bh *bountyHunter) Type() GameModeType {
+	return BountyHunter
+}
+
+func (bh *bountyHunter) OnMatchStart(m *Match, c *actor.Context) {
+	m.after(c, 5*time.Second, "start_round")
+}
+
+func (bh *bountyHunter) OnRoundStart(m *Match, c *actor.Context) {
+	m.phase = PhaseActive
+
+	m.broadcast(c, &RoundStartMsg{
+		Round:      m.currentRound,
+		Phase:      "active",
+		TimeLimit:  int(bh.timeLimit.Seconds()),
+		ScoreLimit: bh.scoreLimit,
+	})
+	bh.broadcastLeaderboard(m, c)
+
+	log.Printf("Bounty Hunter iniziato - %d giocatori, limite %d punti", len(m.teams), bh.scoreLimit)
+
+	m.after(c, bh.timeLimit, "time_limit")
+}
+
+func (bh *bountyHunter) OnTimer(m *Match, c *actor.Context, timer *matchTimer) {
+	switch timer.Name {
+	case "start_round":
+		m.startRound(c)
+	case "time_limit":
+		if m.phase == PhaseActive {
+			m.endMatch(c)
+		}
+	case "respawn":
+		m.respawnPlayer(c, timer.PID)
+	}
+}
+
+func (bh *bountyHunter) AllowAction(m *Match, c *actor.Context, pid *actor.PID, action string) (bool, string) {
+	switch action {
+	case "buy_weapon", "select_loadout", "throw_dynamite", "plant_bomb", "defuse_bomb":
+		return false, "not_available_in_mode"
+	}
+	return true, ""
+}
+
+// OnPlayerKilled scores the kill, raises the killer's bounty and pays out
+// the victim's one, then schedules the respawn.
+func (bh *bountyHunter) OnPlayerKilled(m *Match, c *actor.Context, victim, killer *actor.PID, weapon WeaponType) {
+	if killer != nil && killer != victim {
+		m.playerScores[killer]++
+		bh.bounties[killer] += GetKillReward(weapon)
+
+		if reward := bh.bounties[victim]; reward > 0 {
+			m.playerMoney[killer] += reward
+			bh.bounties[victim] = 0
+
+			m.broadcast(c, &BountyClaimedMsg{
+				Hunter:  playerID(killer),
+				Outlaw:  playerID(victim),
+				Reward:  reward,
+				Balance: m.playerMoney[killer],
+			})
+
+			log.Printf("Taglia di $%d su %s riscossa da %s", reward, victim.String(), killer.String())
+		}
+	}
+
+	bh.broadcastLeaderboard(m, c)
+
+	m.sendToPlayer(c, victim, &RespawnInMsg{Seconds: bh.respawnDelay.Seconds()})
+
+	m.afterFor(c, bh.respawnDelay, "respawn", victim)
+}
+
+// OnPlayerLeft drops the bounty with the player: nobody can collect it anymore.
+func (bh *bountyHunter) OnPlayerLeft(m *Match, c *actor.Context, pid *actor.PID) {
+	delete(bh.bounties, pid)
+	bh.broadcastLeaderboard(m, c)
+}
+
+func (bh *bountyHunter) CheckWinCondition(m *Match, c *actor.Context) {
+	if m.phase != PhaseActive {
+		return
+	}
+	for pid := range m.teams {
+		if m.playerScores[pid] >= bh.scoreLimit {
+			m.endMatch(c)
+			return
+		}
+	}
+}
+
+// WriteState replicates score and bounty on every player entity.
+func (bh *bountyHunter) WriteState(m *Match, world WorldState) {
+	world["match"]["score_limit"] = bh.scoreLimit
+	for pid := range m.teams {
+		if player, ok := world[playerID(pid)]; ok {
+			player["score"] = m.playerScores[pid]
+			player["bounty"] = bh.bounties[pid]
+		}
+	}
+}
+
+// MatchWinner is the player with the best score, or a draw on a tie.
+func (bh *bountyHunter) MatchWinner(m *Match) string {
+	leaderboard := bh.leaderboard(m)
+	if len(leaderboard) == 0 {
+		return "draw"
+	}
+	if len(leaderboard) > 1 && leaderboard[1].Score == leaderboard[0].Score {
+		return "draw"
+	}
+	return leaderboard[0].PlayerID
+}
+
+type bountyEntry struct {
+	PlayerID string `json:"player_id"`
+	Score    int    `json:"score"`
+	Bounty   int    `json:"bounty"`
+}
+
+// leaderboard ranks players by score, then by bounty.
+func (bh *bountyHunter) leaderboard(m *Match) []bountyEntry {
+	entries := make([]bountyEntry, 0, len(m.teams))
+	for pid := range m.teams {
+		entries = append(entries, bountyEntry{
+			PlayerID: playerID(pid),
+			Score:    m.playerScores[pid],
+			Bounty:   bh.bounties[pid],
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Score != entries[j].Score {
+			return entries[i].Score > entries[j].Score
+		}
+		if entries[i].Bounty != entries[j].Bounty {
+			return entries[i].Bounty > entries[j].Bounty
+		}
+		return entries[i].PlayerID < entries[j].PlayerID
+	})
+	return entries
+}
+
+func (bh *bountyHunter) broadcastLeaderboard(m *Match, c *actor.Context) {
+	m.broadcast(c, &BountyLeaderboardMsg{Leaderboard: bh.leaderboard(m)})
+}
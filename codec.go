@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack"
+	"github.com/vmihailenco/msgpack/codes"
+)
+
+// Codec encodes the messages of a connection. Every session starts with
+// JSON for the handshake and switches to the codec agreed in "welcome":
+// JSON is easy to debug, MessagePack is what production clients use.
+type Codec interface {
+	Name() string
+	// FrameType is the websocket frame type the codec reads and writes.
+	FrameType() int
+	Encode(msg ServerMessage) ([]byte, error)
+	Decode(data []byte) (ClientMessage, error)
+}
+
+// Codec supportati, in ordine di preferenza del server
+var codecs = []Codec{msgpackCodec{}, jsonCodec{}}
+
+// negotiateCodec picks the first codec of the client's preference list the
+// server supports, JSON when there is none.
+func negotiateCodec(names []string) Codec {
+	for _, name := range names {
+		for _, codec := range codecs {
+			if codec.Name() == name {
+				return codec
+			}
+		}
+	}
+	return jsonCodec{}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string   { return "json" }
+func (jsonCodec) FrameType() int { return websocket.TextMessage }
+
+// Encode adds "action" to the JSON encoding of msg.
+func (jsonCodec) Encode(msg ServerMessage) ([]byte, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	action, _ := json.Marshal(msg.ActionName())
+
+	out := append([]byte(`{"action":`), action...)
+	if len(body) > 2 {
+		out = append(out, ',')
+	}
+	return append(out, body[1:]...), nil
+}
+
+func (jsonCodec) Decode(data []byte) (ClientMessage, error) {
+	return decodeClientMessage(data, func(data []byte, v interface{}, strict bool) error {
+		if !strict {
+			return json.Unmarshal(data, v)
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		return dec.Decode(v)
+	})
+}
+
+// msgpackCodec uses the same field names as JSON: a message is a map with
+// "action" and the fields of its struct.
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string   { return "msgpack" }
+func (msgpackCodec) FrameType() int { return websocket.BinaryMessage }
+
+// Encode encodes msg as a map and adds "action" to it, growing the map
+// header by one entry.
+func (msgpackCodec) Encode(msg ServerMessage) ([]byte, error) {
+	var body bytes.Buffer
+	enc := msgpack.NewEncoder(&body).UseJSONTag(true).UseCompactEncoding(true)
+	if err := enc.Encode(msg); err != nil {
+		return nil, err
+	}
+
+	b := body.Bytes()
+	var n int
+	switch {
+	case codes.IsFixedMap(codes.Code(b[0])):
+		n, b = int(b[0]&0x0f), b[1:]
+	case codes.Code(b[0]) == codes.Map16:
+		n, b = int(b[1])<<8|int(b[2]), b[3:]
+	default:
+		return nil, fmt.Errorf("msgpack: %s is not encoded as a map", msg.ActionName())
+	}
+
+	var out bytes.Buffer
+	enc = msgpack.NewEncoder(&out)
+	if err := enc.EncodeMapLen(n + 1); err != nil {
+		return nil, err
+	}
+	if err := enc.EncodeString("action"); err != nil {
+		return nil, err
+	}
+	if err := enc.EncodeString(msg.ActionName()); err != nil {
+		return nil, err
+	}
+	out.Write(b)
+	return out.Bytes(), nil
+}
+
+func (msgpackCodec) Decode(data []byte) (ClientMessage, error) {
+	return decodeClientMessage(data, func(data []byte, v interface{}, strict bool) error {
+		if strict {
+			// msgpack salta i campi sconosciuti: li controlliamo a parte
+			var fields map[string]interface{}
+			if err := msgpack.NewDecoder(bytes.NewReader(data)).UseJSONTag(true).Decode(&fields); err != nil {
+				return err
+			}
+			known := jsonFieldNames(reflect.TypeOf(v).Elem())
+			for name := range fields {
+				if !known[name] {
+					return fmt.Errorf("msgpack: unknown field %q", name)
+				}
+			}
+		}
+		return msgpack.NewDecoder(bytes.NewReader(data)).UseJSONTag(true).Decode(v)
+	})
+}
+
+// Nomi JSON dei campi per tipo di messaggio, condivisi tra le sessioni
+var fieldNames sync.Map // reflect.Type -> map[string]bool
+
+// jsonFieldNames returns the JSON names of the fields of struct t, embedded
+// structs included.
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	if names, ok := fieldNames.Load(t); ok {
+		return names.(map[string]bool)
+	}
+
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			for name := range jsonFieldNames(f.Type) {
+				names[name] = true
+			}
+			continue
+		}
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		names[name] = true
+	}
+
+	fieldNames.Store(t, names)
+	return names
+}
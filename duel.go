@@ -0,0 +1,204 @@
+package main
+
+import (
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// Fasi dello stallo prima del "draw"
+type duelStage int
+
+const (
+	duelStandoff duelStage = iota
+	duelReady
+	duelSteady
+	duelDrawn
+)
+
+func (s duelStage) String() string {
+	switch s {
+	case duelStandoff:
+		return "standoff"
+	case duelReady:
+		return "ready"
+	case duelSteady:
+		return "steady"
+	case duelDrawn:
+		return "draw"
+	default:
+		return "unknown"
+	}
+}
+
+// Ritardo casuale tra "steady" e "draw": nessuno deve poterlo anticipare
+const (
+	duelStepDelay    = time.Second
+	duelDrawDelayMin = time.Second
+	duelDrawDelayMax = 4 * time.Second
+)
+
+// duel is the 1v1 quick-draw standoff: the players face each other, the
+// server calls "ready", "steady" and, after a random delay, "draw". Shooting
+// before the draw is a foul and loses the round, the first hit wins it.
+type duel struct {
+	baseMode
+
+	bestOf int
+	weapon WeaponType
+
+	stage    duelStage
+	drawTime time.Time
+}
+
+func newDuel(config MatchConfig) *duel {
+	return &duel{
+		bestOf: config.DuelBestOf,
+		weapon: config.DuelWeapon,
+	}
+}
+
+func (d *duel) Type() GameModeType {
+	return Duel
+}
+
+func (d *duel) OnMatchStart(m *Match, c *actor.Context) {
+	m.after(c, 5*time.Second, "start_round")
+}
+
+// SpawnPoint puts the duellists at the two ends of the standoff.
+func (d *duel) SpawnPoint(m *Match, team Team, slot int) (SpawnPoint, bool) {
+	spot, ok := m.gameMap.DuelSpots[team]
+	return spot, ok
+}
+
+func (d *duel) OnRoundStart(m *Match, c *actor.Context) {
+	m.phase = PhaseActive
+	d.stage = duelStandoff
+
+	// Già uno di fronte all'altro (SpawnPoint): solo l'arma del duello
+	for pid := range m.teams {
+		m.playerWeapons[pid] = newDuelWeapons(d.weapon)
+	}
+
+	m.broadcast(c, &RoundStartMsg{
+		Round:        m.currentRound,
+		Phase:        duelStandoff.String(),
+		BestOf:       d.bestOf,
+		LawmenScore:  m.lawmenScore,
+		OutlawsScore: m.outlawsScore,
+	})
+
+	log.Printf("Duello round %d - Lawmen: %d, Outlaws: %d",
+		m.currentRound, m.lawmenScore, m.outlawsScore)
+
+	m.after(c, duelStepDelay, "duel_ready")
+}
+
+func newDuelWeapons(weapon WeaponType) *PlayerWeapons {
+	weapons := NewPlayerWeapons()
+	weapons.Primary = weapon
+	weapons.Secondary = weapon
+	weapons.Current = weapon
+	weapons.PrimaryAmmo = WeaponStats[weapon].AmmoCapacity
+	weapons.SecondaryAmmo = WeaponStats[weapon].AmmoCapacity
+	return weapons
+}
+
+func (d *duel) OnTimer(m *Match, c *actor.Context, timer *matchTimer) {
+	switch timer.Name {
+	case "start_round":
+		m.startRound(c)
+	case "duel_ready":
+		if d.advance(m, c, duelStandoff, duelReady) {
+			m.after(c, duelStepDelay, "duel_steady")
+		}
+	case "duel_steady":
+		if d.advance(m, c, duelReady, duelSteady) {
+			delay := duelDrawDelayMin + rand.N(duelDrawDelayMax-duelDrawDelayMin)
+			m.after(c, delay, "duel_draw")
+		}
+	case "duel_draw":
+		if d.advance(m, c, duelSteady, duelDrawn) {
+			d.drawTime = time.Now()
+		}
+	}
+}
+
+// advance moves the countdown from one stage to the next and tells the
+// players. Timers left over from an ended round are ignored.
+func (d *duel) advance(m *Match, c *actor.Context, from, to duelStage) bool {
+	if m.phase != PhaseActive || d.stage != from {
+		return false
+	}
+	d.stage = to
+	m.broadcast(c, &DuelCountdownMsg{Step: to.String()})
+	return true
+}
+
+func (d *duel) AllowAction(m *Match, c *actor.Context, pid *actor.PID, action string) (bool, string) {
+	switch action {
+	case "shoot":
+		if m.phase == PhaseActive && d.stage != duelDrawn {
+			d.foul(m, c, pid)
+			return false, "foul"
+		}
+	case "buy_weapon", "select_loadout", "throw_dynamite", "plant_bomb", "defuse_bomb":
+		return false, "not_available_in_mode"
+	}
+	return true, ""
+}
+
+// foul gives the round to the opponent of whoever drew too early.
+func (d *duel) foul(m *Match, c *actor.Context, pid *actor.PID) {
+	winner := TeamLawmen
+	if m.teams[pid] == TeamLawmen {
+		winner = TeamOutlaws
+	}
+	d.endRound(m, c, winner, "Foul: "+m.getPlayerName(pid)+" drew early")
+}
+
+// OnPlayerHit ends the round at the first hit after the draw.
+func (d *duel) OnPlayerHit(m *Match, c *actor.Context, shooter, target *actor.PID) {
+	if m.phase != PhaseActive {
+		return
+	}
+	reaction := time.Since(d.drawTime)
+	d.endRound(m, c, m.teams[shooter], "Quickest draw in "+reaction.Round(time.Millisecond).String())
+}
+
+func (d *duel) WriteState(m *Match, world WorldState) {
+	world["match"]["duel_stage"] = d.stage.String()
+	world["match"]["best_of"] = d.bestOf
+}
+
+func (d *duel) endRound(m *Match, c *actor.Context, winner Team, reason string) {
+	m.phase = PhaseEnd
+	m.cancelRoundTimers(c)
+
+	if winner == TeamLawmen {
+		m.lawmenScore++
+	} else {
+		m.outlawsScore++
+	}
+
+	m.broadcast(c, &RoundEndMsg{
+		Winner:       m.getTeamName(winner),
+		Reason:       reason,
+		LawmenScore:  m.lawmenScore,
+		OutlawsScore: m.outlawsScore,
+	})
+
+	log.Printf("Duello round %d terminato - Vincitore: %s (%s)",
+		m.currentRound, m.getTeamName(winner), reason)
+
+	roundsToWin := d.bestOf/2 + 1
+	if m.lawmenScore >= roundsToWin || m.outlawsScore >= roundsToWin {
+		m.endMatch(c)
+	} else {
+		m.currentRound++
+		m.after(c, 5*time.Second, "start_round")
+	}
+}
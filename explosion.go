@@ -0,0 +1,117 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// Fuoco residuo lasciato dalla dinamite
+const (
+	dynamiteLinger     = 4 * time.Second
+	dynamiteBurnDamage = 8
+)
+
+// detonate applies the blast of e to every living player in range and keeps
+// it around for its lingering effect.
+func (m *Match) detonate(c *actor.Context, e Explosion) {
+	for pid := range m.playersAlive {
+		if !m.playersAlive[pid] {
+			continue
+		}
+		damage := m.explosionDamage(e, pid, e.Radius, e.Damage)
+		if damage <= 0 {
+			continue
+		}
+		m.applyExplosionDamage(c, e.OwnerPID, pid, damage, false)
+	}
+
+	if e.Linger > 0 {
+		e.nextBurn = e.Time.Add(time.Second)
+		m.activeExplosions = append(m.activeExplosions, e)
+	}
+
+	m.mode.CheckWinCondition(m, c)
+}
+
+// explosionDamage computes the damage dealt by e to target: linear falloff
+// from the center, reduced behind cover and scaled for self/friendly fire.
+func (m *Match) explosionDamage(e Explosion, target *actor.PID, radius float64, damage int) int {
+	state, ok := m.playerStates[target]
+	if !ok || radius <= 0 {
+		return 0
+	}
+
+	center := BodyCenter(state.Position, state.Stance)
+	distance := e.Position.Distance(center)
+	if distance > radius {
+		return 0
+	}
+
+	multiplier := math.Max(0.1, 1.0-(distance/radius))
+
+	if m.gameMap.LineBlocked(e.Position, center) {
+		multiplier *= m.config.OccludedDamageFactor
+	}
+
+	if target == e.OwnerPID {
+		multiplier *= m.config.SelfDamageMultiplier
+	} else if e.OwnerPID != nil && m.teams[target] == m.teams[e.OwnerPID] {
+		multiplier *= m.config.FriendlyFireMultiplier
+	}
+
+	return int(float64(damage) * multiplier)
+}
+
+func (m *Match) applyExplosionDamage(c *actor.Context, owner, victim *actor.PID, damage int, burn bool) {
+	m.playerHealth[victim] -= damage
+
+	m.sendToPlayer(c, victim, &ExplosionHitMsg{
+		Burn:   burn,
+		Damage: damage,
+		Health: m.playerHealth[victim],
+	})
+
+	if m.playerHealth[victim] > 0 {
+		return
+	}
+
+	// Award kill to exploder, only for enemies
+	if owner != nil && owner != victim && m.teams[owner] != m.teams[victim] {
+		m.playerMoney[owner] += GetKillReward(WeaponDynamite)
+	}
+	m.killPlayer(c, victim, owner, WeaponDynamite)
+}
+
+// updateExplosions applies the lingering burn of active explosions and drops
+// the expired ones. Called every tick.
+func (m *Match) updateExplosions(c *actor.Context, now time.Time) {
+	active := m.activeExplosions[:0]
+	burned := false
+
+	for _, e := range m.activeExplosions {
+		if now.Sub(e.Time) > e.Linger {
+			continue
+		}
+		for !now.Before(e.nextBurn) {
+			for pid := range m.playersAlive {
+				if !m.playersAlive[pid] {
+					continue
+				}
+				damage := m.explosionDamage(e, pid, e.Radius/2, e.BurnDamage)
+				if damage > 0 {
+					m.applyExplosionDamage(c, e.OwnerPID, pid, damage, true)
+					burned = true
+				}
+			}
+			e.nextBurn = e.nextBurn.Add(time.Second)
+		}
+		active = append(active, e)
+	}
+
+	m.activeExplosions = active
+	if burned {
+		m.mode.CheckWinCondition(m, c)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+type GameModeType int
+
+const (
+	SearchAndDestroy GameModeType = iota
+	TeamDeathmatch
+	Duel
+	BountyHunter
+	GoldRush
+)
+
+func (g GameModeType) String() string {
+	switch g {
+	case SearchAndDestroy:
+		return "search_destroy"
+	case TeamDeathmatch:
+		return "team_deathmatch"
+	case Duel:
+		return "duel"
+	case BountyHunter:
+		return "bounty_hunter"
+	case GoldRush:
+		return "gold_rush"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseGameModeType maps the name used by clients back to a GameModeType.
+func ParseGameModeType(name string) (GameModeType, bool) {
+	for _, mode := range []GameModeType{SearchAndDestroy, TeamDeathmatch, Duel, BountyHunter, GoldRush} {
+		if mode.String() == name {
+			return mode, true
+		}
+	}
+	return SearchAndDestroy, false
+}
+
+// Lineup returns how many teams a match of mode has and how many players
+// sit in each.
+func (g GameModeType) Lineup(config MatchConfig) (teams, teamSize int) {
+	switch g {
+	case Duel:
+		return 2, 1
+	case BountyHunter:
+		return config.BountyPlayers, 1
+	default:
+		return 2, config.TeamSize
+	}
+}
+
+// IsFreeForAll reports whether every player of mode plays for itself.
+func (g GameModeType) IsFreeForAll() bool {
+	return g == BountyHunter
+}
+
+// GameMode holds the rules of a match: rounds, objectives, scoring and win
+// conditions. The Match actor owns players, simulation and networking and
+// calls the hooks from inside Receive, so a GameMode never needs locking.
+type GameMode interface {
+	Type() GameModeType
+
+	// OnMatchStart runs once, after every player got "match_joined".
+	OnMatchStart(m *Match, c *actor.Context)
+	// SpawnPoint chooses where the slot-th player of team spawns when a
+	// round starts. Without one the player gets the map team spawns.
+	SpawnPoint(m *Match, team Team, slot int) (SpawnPoint, bool)
+	// OnRoundStart runs after Match.startRound reset and spawned the players.
+	OnRoundStart(m *Match, c *actor.Context)
+	// OnTick runs every simulation tick, before the snapshot is sent.
+	OnTick(m *Match, c *actor.Context, now time.Time)
+	// OnTimer receives the timers scheduled with Match.after.
+	OnTimer(m *Match, c *actor.Context, timer *matchTimer)
+
+	// AllowAction can veto any client action with a reason for the client.
+	AllowAction(m *Match, c *actor.Context, pid *actor.PID, action string) (bool, string)
+	// OnObjectiveAction handles the actions the Match core does not know
+	// about. It reports whether the action was handled.
+	OnObjectiveAction(m *Match, c *actor.Context, pid *actor.PID, msg ClientMessage) bool
+
+	// OnPlayerHit runs after a shot damaged target, before death handling.
+	OnPlayerHit(m *Match, c *actor.Context, shooter, target *actor.PID)
+	// OnPlayerKilled runs once victim is dead; weapon is what killed it.
+	OnPlayerKilled(m *Match, c *actor.Context, victim, killer *actor.PID, weapon WeaponType)
+	// OnPlayerLeft runs when pid disconnected, after it died and left its
+	// team; its state is still readable.
+	OnPlayerLeft(m *Match, c *actor.Context, pid *actor.PID)
+	// CheckWinCondition ends the round or the match when the mode says so.
+	CheckWinCondition(m *Match, c *actor.Context)
+
+	// WriteState adds mode specific fields to the replicated world, usually
+	// to the "match" entity.
+	WriteState(m *Match, world WorldState)
+	// MatchWinner names the winner announced in "match_end".
+	MatchWinner(m *Match) string
+}
+
+func newGameMode(mode GameModeType, config MatchConfig) GameMode {
+	switch mode {
+	case TeamDeathmatch:
+		return newTeamDeathmatch(config)
+	case Duel:
+		return newDuel(config)
+	case BountyHunter:
+		return newBountyHunter(config)
+	case GoldRush:
+		return newGoldRush(config)
+	default:
+		return newSearchAndDestroy()
+	}
+}
+
+// baseMode provides no-op hooks to embed in GameMode implementations.
+type baseMode struct{}
+
+func (baseMode) OnMatchStart(m *Match, c *actor.Context)               {}
+func (baseMode) OnRoundStart(m *Match, c *actor.Context)               {}
+func (baseMode) OnTick(m *Match, c *actor.Context, now time.Time)      {}
+func (baseMode) OnTimer(m *Match, c *actor.Context, timer *matchTimer) {}
+func (baseMode) CheckWinCondition(m *Match, c *actor.Context)          {}
+func (baseMode) WriteState(m *Match, world WorldState)                 {}
+
+func (baseMode) SpawnPoint(m *Match, team Team, slot int) (SpawnPoint, bool) {
+	return SpawnPoint{}, false
+}
+
+func (baseMode) OnPlayerHit(m *Match, c *actor.Context, shooter, target *actor.PID) {}
+func (baseMode) OnPlayerKilled(m *Match, c *actor.Context, victim, killer *actor.PID, weapon WeaponType) {
+}
+func (baseMode) OnPlayerLeft(m *Match, c *actor.Context, pid *actor.PID) {}
+
+// MatchWinner defaults to the team with the best score, or a draw.
+func (baseMode) MatchWinner(m *Match) string {
+	switch {
+	case m.lawmenScore > m.outlawsScore:
+		return m.getTeamName(TeamLawmen)
+	case m.outlawsScore > m.lawmenScore:
+		return m.getTeamName(TeamOutlaws)
+	default:
+		return "draw"
+	}
+}
+
+func (baseMode) AllowAction(m *Match, c *actor.Context, pid *actor.PID, action string) (bool, string) {
+	return true, ""
+}
+
+func (baseMode) OnObjectiveAction(m *Match, c *actor.Context, pid *actor.PID, msg ClientMessage) bool {
+	return false
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// goldRush is the control point mode: teams capture the gold zones of the
+// map by standing inside them and every held zone pays gold to its team
+// each second. First team to the gold target, or the richest when time
+// runs out, wins. Players respawn like in Team Deathmatch.
+type goldRush struct {
+	baseMode
+
+	target       int
+	goldRate     float64
+	captureTime  time.Duration
+	timeLimit    time.Duration
+	respawnDelay time.Duration
+
+	zones    []*goldZone
+	gold     map[Team]float64
+	lastTick time.Time
+}
+
+// goldZone tracks the capture of a map zone. progress belongs to team: it
+// fills up while team holds the zone alone and drains while an enemy does.
+// The zone is captured by team at 1 and turns neutral again at 0.
+type goldZone struct {
+	Zone
+
+	team      Team
+	progress  float64
+	captured  bool
+	contested bool
+}
+
+// Più giocatori conquistano più in fretta, fino a un massimo
+const maxCaptureMultiplier = 3
+
+func newGoldRush(config MatchConfig) *goldRush {
+	return &goldRush{
+		target:       config.GoldTarget,
+		goldRate:     config.GoldPerSecond,
+		captureTime:  config.GoldCaptureTime,
+		timeLimit:    config.GoldTimeLimit,
+		respawnDelay: config.RespawnDelay,
+		gold:         make(map[Team]float64),
+	}
+}
+
+func (gr *goldRush) Type() GameModeType {
+	return GoldRush
+}
+
+func (gr *goldRush) OnMatchStart(m *Match, c *actor.Context) {
+	for _, zone := range m.gameMap.GoldZones {
+		gr.zones = append(gr.zones, &goldZone{Zone: zone})
+	}
+	m.after(c, 5*time.Second, "start_round")
+}
+
+func (gr *goldRush) OnRoundStart(m *Match, c *actor.Context) {
+	m.phase = PhaseActive
+	gr.lastTick = time.Now()
+
+	for pid := range m.teams {
+		m.playerWeapons[pid].Dynamite = spawnDynamite
+	}
+
+	zones := make([]ZoneInfo, len(gr.zones))
+	for i, zone := range gr.zones {
+		zones[i] = ZoneInfo{
+			Zone:   zone.Name,
+			X:      zone.Center.X,
+			Y:      zone.Center.Y,
+			Z:      zone.Center.Z,
+			Radius: zone.Radius,
+		}
+	}
+
+	m.broadcast(c, &RoundStartMsg{
+		Round:        m.currentRound,
+		Phase:        "active",
+		TimeLimit:    int(gr.timeLimit.Seconds()),
+		GoldTarget:   gr.target,
+		Zones:        zones,
+		LawmenScore:  m.lawmenScore,
+		OutlawsScore: m.outlawsScore,
+	})
+
+	log.Printf("Gold Rush iniziato - %d zone, obiettivo %d oro", len(gr.zones), gr.target)
+
+	m.after(c, gr.timeLimit, "time_limit")
+}
+
+func (gr *goldRush) OnTimer(m *Match, c *actor.Context, timer *matchTimer) {
+	switch timer.Name {
+	case "start_round":
+		m.startRound(c)
+	case "time_limit":
+		if m.phase == PhaseActive {
+			m.endMatch(c)
+		}
+	case "respawn":
+		if m.respawnPlayer(c, timer.PID) {
+			m.playerWeapons[timer.PID].Dynamite = spawnDynamite
+		}
+	}
+}
+
+func (gr *goldRush) AllowAction(m *Match, c *actor.Context, pid *actor.PID, action string) (bool, string) {
+	switch action {
+	case "buy_weapon", "select_loadout", "plant_bomb", "defuse_bomb":
+		return false, "not_available_in_mode"
+	}
+	return true, ""
+}
+
+func (gr *goldRush) OnPlayerKilled(m *Match, c *actor.Context, victim, killer *actor.PID, weapon WeaponType) {
+	m.sendToPlayer(c, victim, &RespawnInMsg{Seconds: gr.respawnDelay.Seconds()})
+
+	m.afterFor(c, gr.respawnDelay, "respawn", victim)
+}
+
+// OnTick updates every zone from the players standing in it, then pays the
+// gold of the held zones.
+func (gr *goldRush) OnTick(m *Match, c *actor.Context, now time.Time) {
+	dt := now.Sub(gr.lastTick).Seconds()
+	gr.lastTick = now
+
+	for _, zone := range gr.zones {
+		if gr.updateZone(m, zone, dt) {
+			m.broadcast(c, gr.zoneUpdate(m, zone))
+		}
+		if zone.captured {
+			gr.gold[zone.team] += gr.goldRate * dt
+		}
+	}
+
+	m.lawmenScore = int(gr.gold[TeamLawmen])
+	m.outlawsScore = int(gr.gold[TeamOutlaws])
+
+	gr.CheckWinCondition(m, c)
+}
+
+// updateZone advances the capture of zone by dt seconds. It reports whether
+// the owner or the contested state changed.
+func (gr *goldRush) updateZone(m *Match, zone *goldZone, dt float64) bool {
+	present := make(map[Team]int)
+	for pid, state := range m.playerStates {
+		if m.playersAlive[pid] && zone.Contains(state.Position) {
+			present[m.teams[pid]]++
+		}
+	}
+
+	changed := false
+	contested := len(present) > 1
+	if contested != zone.contested {
+		zone.contested = contested
+		changed = true
+	}
+	if len(present) != 1 {
+		// Vuota o contesa: la conquista resta ferma
+		return changed
+	}
+
+	var team Team
+	var count int
+	for t, n := range present {
+		team, count = t, n
+	}
+	rate := dt / gr.captureTime.Seconds() * float64(min(count, maxCaptureMultiplier))
+
+	if zone.team != team {
+		// Prima si neutralizza la zona nemica, poi si conquista
+		zone.progress -= rate
+		if zone.progress > 0 {
+			return changed
+		}
+		zone.progress = 0
+		zone.team = team
+		if zone.captured {
+			zone.captured = false
+			changed = true
+		}
+		return changed
+	}
+
+	zone.progress = min(1, zone.progress+rate)
+	if zone.progress >= 1 && !zone.captured {
+		zone.captured = true
+		changed = true
+		log.Printf("Zona %s conquistata dai %s", zone.Name, m.getTeamName(team))
+	}
+	return changed
+}
+
+func (gr *goldRush) zoneUpdate(m *Match, zone *goldZone) *ZoneUpdateMsg {
+	owner, capturing := "none", "none"
+	if zone.captured {
+		owner = m.getTeamName(zone.team)
+	}
+	if zone.progress > 0 {
+		capturing = m.getTeamName(zone.team)
+	}
+	return &ZoneUpdateMsg{
+		Zone:      zone.Name,
+		Owner:     owner,
+		Capturing: capturing,
+		Progress:  zone.progress,
+		Contested: zone.contested,
+	}
+}
+
+func (gr *goldRush) CheckWinCondition(m *Match, c *actor.Context) {
+	if m.phase != PhaseActive {
+		return
+	}
+	if m.lawmenScore >= gr.target || m.outlawsScore >= gr.target {
+		m.endMatch(c)
+	}
+}
+
+// WriteState replicates every zone as its own entity, so clients can draw
+// the capture progress between "zone_update" messages.
+func (gr *goldRush) WriteState(m *Match, world WorldState) {
+	world["match"]["gold_target"] = gr.target
+	for _, zone := range gr.zones {
+		update := gr.zoneUpdate(m, zone)
+		world["zone:"+zone.Name] = map[string]interface{}{
+			"owner":     update.Owner,
+			"capturing": update.Capturing,
+			"progress":  update.Progress,
+			"contested": update.Contested,
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import "math"
+
+// Dimensioni hitbox del giocatore, relative alla posizione dei piedi
+// (il pivot del transform Unity).
+const (
+	playerEyeHeight   = 1.6
+	bodyCapsuleBottom = 0.35
+	bodyCapsuleTop    = 1.15
+	bodyCapsuleRadius = 0.35
+	headCenterHeight  = 1.6
+	headRadius        = 0.16
+
+	// Da accovacciato il corpo si abbassa
+	crouchEyeHeight      = 1.1
+	crouchBodyCapsuleTop = 0.7
+	crouchHeadHeight     = 1.1
+
+	// Distanza massima tra l'origine dichiarata dal client e gli occhi
+	// del giocatore sul server prima di scartarla.
+	maxShotOriginOffset = 1.5
+)
+
+func (p Position) Add(o Position) Position {
+	return Position{X: p.X + o.X, Y: p.Y + o.Y, Z: p.Z + o.Z}
+}
+
+func (p Position) Sub(o Position) Position {
+	return Position{X: p.X - o.X, Y: p.Y - o.Y, Z: p.Z - o.Z}
+}
+
+func (p Position) Scale(s float64) Position {
+	return Position{X: p.X * s, Y: p.Y * s, Z: p.Z * s}
+}
+
+func (p Position) Dot(o Position) float64 {
+	return p.X*o.X + p.Y*o.Y + p.Z*o.Z
+}
+
+func (p Position) Length() float64 {
+	return math.Sqrt(p.Dot(p))
+}
+
+func (p Position) Distance(o Position) float64 {
+	return p.Sub(o).Length()
+}
+
+// Normalize returns the unit vector, or the zero vector for a zero input.
+func (p Position) Normalize() Position {
+	l := p.Length()
+	if l == 0 {
+		return Position{}
+	}
+	return p.Scale(1 / l)
+}
+
+// Capsule is a segment A-B swept by Radius. A capsule with A == B is a sphere.
+type Capsule struct {
+	A      Position
+	B      Position
+	Radius float64
+}
+
+// IntersectRay returns the distance along dir (unit length) from origin to the
+// first point of the capsule, if it lies within maxDist.
+func (cp Capsule) IntersectRay(origin, dir Position, maxDist float64) (float64, bool) {
+	best := math.Inf(1)
+
+	// Cilindro tra A e B
+	ba := cp.B.Sub(cp.A)
+	oa := origin.Sub(cp.A)
+	baba := ba.Dot(ba)
+	bard := ba.Dot(dir)
+	baoa := ba.Dot(oa)
+	a := baba - bard*bard
+	if a > 1e-9 {
+		b := baba*dir.Dot(oa) - baoa*bard
+		c := baba*oa.Dot(oa) - baoa*baoa - cp.Radius*cp.Radius*baba
+		h := b*b - a*c
+		if h >= 0 {
+			t := (-b - math.Sqrt(h)) / a
+			y := baoa + t*bard
+			if t >= 0 && y > 0 && y < baba {
+				best = t
+			}
+		}
+	}
+
+	// Calotte sferiche alle estremità
+	for _, center := range [2]Position{cp.A, cp.B} {
+		if t, ok := raySphere(origin, dir, center, cp.Radius); ok && t < best {
+			best = t
+		}
+	}
+
+	if best > maxDist {
+		return 0, false
+	}
+	return best, true
+}
+
+func raySphere(origin, dir, center Position, radius float64) (float64, bool) {
+	oc := origin.Sub(center)
+	b := oc.Dot(dir)
+	c := oc.Dot(oc) - radius*radius
+	h := b*b - c
+	if h < 0 {
+		return 0, false
+	}
+	t := -b - math.Sqrt(h)
+	if t < 0 {
+		return 0, false
+	}
+	return t, true
+}
+
+// PlayerHitboxes builds the body and head capsules of a player at pos.
+func PlayerHitboxes(pos Position, stance Stance) (body, head Capsule) {
+	top, headHeight := bodyCapsuleTop, headCenterHeight
+	if stance == StanceCrouching {
+		top, headHeight = crouchBodyCapsuleTop, crouchHeadHeight
+	}
+	body = Capsule{
+		A:      pos.Add(Position{Y: bodyCapsuleBottom}),
+		B:      pos.Add(Position{Y: top}),
+		Radius: bodyCapsuleRadius,
+	}
+	headPos := pos.Add(Position{Y: headHeight})
+	head = Capsule{A: headPos, B: headPos, Radius: headRadius}
+	return body, head
+}
+
+// EyePosition is where shots of a player at pos originate from.
+func EyePosition(pos Position, stance Stance) Position {
+	if stance == StanceCrouching {
+		return pos.Add(Position{Y: crouchEyeHeight})
+	}
+	return pos.Add(Position{Y: playerEyeHeight})
+}
+
+// BodyCenter is the middle of the body capsule, used for area damage.
+func BodyCenter(pos Position, stance Stance) Position {
+	body, _ := PlayerHitboxes(pos, stance)
+	return body.A.Add(body.B).Scale(0.5)
+}
+
+// TraceShot tests a ray against a player's hitboxes. It reports the distance
+// to the impact and whether the head was hit first.
+func TraceShot(origin, dir Position, maxDist float64, target Position, stance Stance) (distance float64, headshot bool, hit bool) {
+	body, head := PlayerHitboxes(target, stance)
+
+	headDist, headHit := head.IntersectRay(origin, dir, maxDist)
+	bodyDist, bodyHit := body.IntersectRay(origin, dir, maxDist)
+
+	switch {
+	case headHit && (!bodyHit || headDist <= bodyDist):
+		return headDist, true, true
+	case bodyHit:
+		return bodyDist, false, true
+	}
+	return 0, false, false
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCapsuleIntersectRay(t *testing.T) {
+	cylinder := Capsule{A: Position{}, B: Position{Y: 2}, Radius: 0.5}
+	sphere := Capsule{Radius: 1}
+
+	tests := []struct {
+		name    string
+		capsule Capsule
+		origin  Position
+		dir     Position
+		maxDist float64
+		want    float64
+		hit     bool
+	}{
+		{"side of the cylinder", cylinder, Position{X: -5, Y: 1}, Position{X: 1}, 10, 4.5, true},
+		{"top cap", cylinder, Position{Y: 5}, Position{Y: -1}, 10, 2.5, true},
+		{"bottom cap", cylinder, Position{Y: -5}, Position{Y: 1}, 10, 4.5, true},
+		{"beyond maxDist", cylinder, Position{X: -5, Y: 1}, Position{X: 1}, 4, 0, false},
+		{"pointing away", cylinder, Position{X: -5, Y: 1}, Position{X: -1}, 10, 0, false},
+		{"parallel outside", cylinder, Position{X: 1, Y: -5}, Position{Y: 1}, 10, 0, false},
+		{"passing above", cylinder, Position{X: -5, Y: 3}, Position{X: 1}, 10, 0, false},
+		{"sphere", sphere, Position{Z: -3}, Position{Z: 1}, 10, 2, true},
+		{"sphere grazed", sphere, Position{X: 0.99, Z: -3}, Position{Z: 1}, 10, 3 - math.Sqrt(1-0.99*0.99), true},
+		{"sphere missed", sphere, Position{X: 1.01, Z: -3}, Position{Z: 1}, 10, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, hit := tt.capsule.IntersectRay(tt.origin, tt.dir, tt.maxDist)
+			if hit != tt.hit {
+				t.Fatalf("hit = %v, want %v", hit, tt.hit)
+			}
+			if hit && math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("distance = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTraceShot(t *testing.T) {
+	// Bersaglio con i piedi nell'origine, colpi lungo +Z da 10 m
+	target := Position{}
+	dir := Position{Z: 1}
+	from := func(x, y float64) Position { return Position{X: x, Y: y, Z: -10} }
+
+	tests := []struct {
+		name     string
+		origin   Position
+		stance   Stance
+		distance float64
+		headshot bool
+		hit      bool
+	}{
+		{"head standing", from(0, headCenterHeight), StanceStanding, 10 - headRadius, true, true},
+		{"body standing", from(0, 1), StanceStanding, 10 - bodyCapsuleRadius, false, true},
+		{"legs standing", from(0, 0.2), StanceStanding, 10 - math.Sqrt(bodyCapsuleRadius*bodyCapsuleRadius-0.15*0.15), false, true},
+		{"wide miss", from(1, 1), StanceStanding, 0, false, false},
+		{"over the head", from(0, 2), StanceStanding, 0, false, false},
+		{"head height misses crouching", from(0, headCenterHeight), StanceCrouching, 0, false, false},
+		{"head crouching", from(0, crouchHeadHeight), StanceCrouching, 10 - headRadius, true, true},
+		// Il corpo davanti alla testa vince
+		{"shoulder before head crouching", from(0, 1), StanceCrouching, 10 - math.Sqrt(bodyCapsuleRadius*bodyCapsuleRadius-0.3*0.3), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			distance, headshot, hit := TraceShot(tt.origin, dir, 100, target, tt.stance)
+			if hit != tt.hit || headshot != tt.headshot {
+				t.Fatalf("hit = %v headshot = %v, want %v %v", hit, headshot, tt.hit, tt.headshot)
+			}
+			if hit && math.Abs(distance-tt.distance) > 1e-9 {
+				t.Errorf("distance = %v, want %v", distance, tt.distance)
+			}
+		})
+	}
+}
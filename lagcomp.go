@@ -0,0 +1,117 @@
+package main
+
+import (
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// Campioni conservati per player: a 60 Hz coprono circa un secondo,
+// ben oltre la finestra massima di rewind.
+const positionHistorySize = 64
+
+// Peso del nuovo campione nella media mobile dell'RTT
+const rttSmoothing = 0.125
+
+type positionSample struct {
+	at       time.Time
+	position Position
+	stance   Stance
+}
+
+// positionHistory is a ring buffer of past positions of a player, used to
+// rewind targets to the moment the shooter saw them.
+type positionHistory struct {
+	samples [positionHistorySize]positionSample
+	next    int
+	count   int
+}
+
+func (h *positionHistory) record(at time.Time, position Position, stance Stance) {
+	h.samples[h.next] = positionSample{at: at, position: position, stance: stance}
+	h.next = (h.next + 1) % positionHistorySize
+	if h.count < positionHistorySize {
+		h.count++
+	}
+}
+
+// sample returns the i-th sample, 0 being the most recent.
+func (h *positionHistory) sample(i int) positionSample {
+	return h.samples[(h.next-1-i+positionHistorySize)%positionHistorySize]
+}
+
+// At returns the interpolated position at t. Times outside the recorded
+// range are clamped to the oldest or newest sample.
+func (h *positionHistory) At(t time.Time) (Position, Stance, bool) {
+	if h.count == 0 {
+		return Position{}, StanceStanding, false
+	}
+
+	newer := h.sample(0)
+	if !t.Before(newer.at) {
+		return newer.position, newer.stance, true
+	}
+
+	for i := 1; i < h.count; i++ {
+		older := h.sample(i)
+		if !t.Before(older.at) {
+			span := newer.at.Sub(older.at).Seconds()
+			frac := 0.0
+			if span > 0 {
+				frac = t.Sub(older.at).Seconds() / span
+			}
+			pos := older.position.Add(newer.position.Sub(older.position).Scale(frac))
+			return pos, older.stance, true
+		}
+		newer = older
+	}
+	return newer.position, newer.stance, true
+}
+
+// recordPositions stores the current position of every living player.
+func (m *Match) recordPositions(now time.Time) {
+	for pid, state := range m.playerStates {
+		if !m.playersAlive[pid] {
+			continue
+		}
+		history, ok := m.positionHistory[pid]
+		if !ok {
+			history = &positionHistory{}
+			m.positionHistory[pid] = history
+		}
+		history.record(now, state.Position, state.Stance)
+	}
+}
+
+// updateRTT folds a round trip sample measured from a snapshot ack into the
+// smoothed estimate for player.
+func (m *Match) updateRTT(player *actor.PID, sample time.Duration) {
+	rtt, ok := m.playerRTT[player]
+	if !ok {
+		m.playerRTT[player] = sample
+		return
+	}
+	m.playerRTT[player] = rtt + time.Duration(float64(sample-rtt)*rttSmoothing)
+}
+
+// rewindTime estimates when shooter saw the world it is shooting at: half
+// the round trip plus the client interpolation delay, capped by MaxRewind.
+func (m *Match) rewindTime(shooter *actor.PID, now time.Time) time.Time {
+	rewind := m.playerRTT[shooter]/2 + m.config.InterpDelay
+	if rewind > m.config.MaxRewind {
+		rewind = m.config.MaxRewind
+	}
+	return now.Add(-rewind)
+}
+
+// rewoundPosition returns where target was at t, falling back to its
+// current state when there is no history yet.
+func (m *Match) rewoundPosition(target *actor.PID, t time.Time) (Position, Stance, bool) {
+	if history, ok := m.positionHistory[target]; ok {
+		return history.At(t)
+	}
+	if state, ok := m.playerStates[target]; ok {
+		return state.Position, state.Stance, true
+	}
+	return Position{}, StanceStanding, false
+}
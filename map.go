@@ -0,0 +1,171 @@
+package main
+
+import "math"
+
+// Box is an axis-aligned solid of the map geometry (buildings, crates, walls).
+type Box struct {
+	Min Position
+	Max Position
+}
+
+// IntersectsSegment reports whether the segment a-b passes through the box
+// (slab test).
+func (b Box) IntersectsSegment(a, c Position) bool {
+	dir := c.Sub(a)
+	tMin, tMax := 0.0, 1.0
+
+	axes := [3][4]float64{
+		{a.X, dir.X, b.Min.X, b.Max.X},
+		{a.Y, dir.Y, b.Min.Y, b.Max.Y},
+		{a.Z, dir.Z, b.Min.Z, b.Max.Z},
+	}
+	for _, axis := range axes {
+		origin, d, lo, hi := axis[0], axis[1], axis[2], axis[3]
+		if math.Abs(d) < 1e-9 {
+			if origin < lo || origin > hi {
+				return false
+			}
+			continue
+		}
+		t1 := (lo - origin) / d
+		t2 := (hi - origin) / d
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		tMin = math.Max(tMin, t1)
+		tMax = math.Min(tMax, t2)
+		if tMin > tMax {
+			return false
+		}
+	}
+	return true
+}
+
+// SpawnPoint is where a player appears, facing Yaw (degrees).
+type SpawnPoint struct {
+	Position Position
+	Yaw      float64
+}
+
+// Zone is a circular area of the map, e.g. a Gold Rush capture point. Only
+// the horizontal distance counts: standing on a roof above it still holds it.
+type Zone struct {
+	Name   string
+	Center Position
+	Radius float64
+}
+
+func (z Zone) Contains(pos Position) bool {
+	dx, dz := pos.X-z.Center.X, pos.Z-z.Center.Z
+	return dx*dx+dz*dz <= z.Radius*z.Radius
+}
+
+// ZoneAt returns the zone of zones containing pos.
+func ZoneAt(zones []Zone, pos Position) (Zone, bool) {
+	for _, zone := range zones {
+		if zone.Contains(pos) {
+			return zone, true
+		}
+	}
+	return Zone{}, false
+}
+
+// GameMap is the server-side description of a map: only what the server
+// needs to judge gameplay, not the visuals.
+type GameMap struct {
+	Name             string
+	Bounds           Box // volume giocabile: dal terreno a poco sopra i tetti
+	Occluders        []Box
+	SpawnPoints      map[Team][]SpawnPoint
+	FreeForAllSpawns []SpawnPoint        // shared by every solo team
+	DuelSpots        map[Team]SpawnPoint // the two ends of the standoff
+	GoldZones        []Zone
+	BombSites        []Zone
+}
+
+// LineBlocked reports whether any occluder sits between a and b.
+func (gm *GameMap) LineBlocked(a, b Position) bool {
+	for _, box := range gm.Occluders {
+		if box.IntersectsSegment(a, b) {
+			return true
+		}
+	}
+	return false
+}
+
+const defaultMapName = "dusty_town"
+
+var Maps = map[string]*GameMap{
+	"dusty_town": {
+		Name: "dusty_town",
+		// Il tetto più alto è la banca, 8 m: sopra resta solo lo spazio di un salto
+		Bounds: Box{Min: Position{X: -40, Y: 0, Z: -40}, Max: Position{X: 40, Y: 10, Z: 40}},
+		Occluders: []Box{
+			// Saloon
+			{Min: Position{X: -20, Y: 0, Z: 5}, Max: Position{X: -8, Y: 7, Z: 18}},
+			// Banca
+			{Min: Position{X: 8, Y: 0, Z: 5}, Max: Position{X: 20, Y: 8, Z: 16}},
+			// Stalla
+			{Min: Position{X: -18, Y: 0, Z: -20}, Max: Position{X: -6, Y: 6, Z: -8}},
+			// Ufficio dello sceriffo
+			{Min: Position{X: 6, Y: 0, Z: -18}, Max: Position{X: 16, Y: 5, Z: -8}},
+			// Carri e casse sulla strada principale
+			{Min: Position{X: -2, Y: 0, Z: -3}, Max: Position{X: 2, Y: 2, Z: 3}},
+			{Min: Position{X: -5, Y: 0, Z: 10}, Max: Position{X: -3.5, Y: 1.2, Z: 11.5}},
+			{Min: Position{X: 4, Y: 0, Z: -12}, Max: Position{X: 5.5, Y: 1.2, Z: -10.5}},
+		},
+		SpawnPoints: map[Team][]SpawnPoint{
+			// Lawmen davanti all'ufficio dello sceriffo, a sud
+			TeamLawmen: {
+				{Position: Position{X: 0, Y: 0, Z: -30}, Yaw: 0},
+				{Position: Position{X: -3, Y: 0, Z: -31}, Yaw: 0},
+				{Position: Position{X: 3, Y: 0, Z: -31}, Yaw: 0},
+				{Position: Position{X: -6, Y: 0, Z: -29}, Yaw: 0},
+				{Position: Position{X: 6, Y: 0, Z: -29}, Yaw: 0},
+			},
+			// Outlaws fuori dal saloon, a nord
+			TeamOutlaws: {
+				{Position: Position{X: 0, Y: 0, Z: 30}, Yaw: 180},
+				{Position: Position{X: -3, Y: 0, Z: 31}, Yaw: 180},
+				{Position: Position{X: 3, Y: 0, Z: 31}, Yaw: 180},
+				{Position: Position{X: -6, Y: 0, Z: 29}, Yaw: 180},
+				{Position: Position{X: 6, Y: 0, Z: 29}, Yaw: 180},
+			},
+		},
+		// Sparsi per tutta la città, lontani l'uno dall'altro
+		FreeForAllSpawns: []SpawnPoint{
+			{Position: Position{X: 0, Y: 0, Z: -30}, Yaw: 0},
+			{Position: Position{X: 0, Y: 0, Z: 30}, Yaw: 180},
+			{Position: Position{X: -28, Y: 0, Z: 0}, Yaw: 90},
+			{Position: Position{X: 28, Y: 0, Z: 0}, Yaw: 270},
+			{Position: Position{X: -24, Y: 0, Z: -26}, Yaw: 45},
+			{Position: Position{X: 24, Y: 0, Z: 26}, Yaw: 225},
+			{Position: Position{X: -24, Y: 0, Z: 26}, Yaw: 135},
+			{Position: Position{X: 24, Y: 0, Z: -26}, Yaw: 315},
+		},
+		// Venti passi sulla strada principale
+		DuelSpots: map[Team]SpawnPoint{
+			TeamLawmen:  {Position: Position{X: 0, Y: 0, Z: -10}, Yaw: 0},
+			TeamOutlaws: {Position: Position{X: 0, Y: 0, Z: 10}, Yaw: 180},
+		},
+		// Carri dell'oro: davanti al saloon, in mezzo alla strada, davanti alla banca
+		GoldZones: []Zone{
+			{Name: "saloon", Center: Position{X: -14, Y: 0, Z: 0}, Radius: 5},
+			{Name: "main_street", Center: Position{X: 0, Y: 0, Z: 0}, Radius: 6},
+			{Name: "bank", Center: Position{X: 14, Y: 0, Z: 0}, Radius: 5},
+		},
+		// Dove si piazza la dinamite: la cassaforte della banca e la stalla
+		BombSites: []Zone{
+			{Name: "A", Center: Position{X: 14, Y: 0, Z: 2}, Radius: 4},
+			{Name: "B", Center: Position{X: -12, Y: 0, Z: -4}, Radius: 4},
+		},
+	},
+}
+
+// GetMap returns the named map, falling back to the default one.
+func GetMap(name string) *GameMap {
+	if gm, ok := Maps[name]; ok {
+		return gm
+	}
+	return Maps[defaultMapName]
+}
@@ -1,10 +1,8 @@
 package main
 
 import (
-	"encoding/json"
+	"fmt"
 	"log"
-	"math"
-	"math/rand/v2"
 	"time"
 
 	"github.com/anthdm/hollywood/actor"
@@ -15,14 +13,32 @@ type Team int
 const (
 	TeamLawmen Team = iota
 	TeamOutlaws
+
+	// Nel free-for-all ogni giocatore è una squadra a sé, a partire da qui
+	firstSoloTeam
 )
 
-type GameMode int
+// SoloTeam returns the team of the i-th player of a free-for-all match.
+func SoloTeam(i int) Team {
+	return firstSoloTeam + Team(i)
+}
 
-const (
-	SearchAndDestroy GameMode = iota
-	TeamDeathmatch
-)
+func (t Team) IsSolo() bool {
+	return t >= firstSoloTeam
+}
+
+func (t Team) String() string {
+	switch t {
+	case TeamLawmen:
+		return "lawmen"
+	case TeamOutlaws:
+		return "outlaws"
+	}
+	if t.IsSolo() {
+		return fmt.Sprintf("solo_%d", t-firstSoloTeam)
+	}
+	return "unknown"
+}
 
 type RoundPhase int
 
@@ -33,28 +49,125 @@ const (
 	PhaseEnd
 )
 
+func (p RoundPhase) String() string {
+	switch p {
+	case PhaseWarmup:
+		return "warmup"
+	case PhaseBuyTime:
+		return "buy_time"
+	case PhaseActive:
+		return "active"
+	case PhaseEnd:
+		return "end"
+	default:
+		return "unknown"
+	}
+}
+
+// MatchConfig holds the tunables of a Match.
+type MatchConfig struct {
+	Mode     GameModeType
+	TeamSize int // players per team, 1v1 up to 5v5
+	TickRate int // simulation ticks per second (20, 30, 60)
+
+	// Lag compensation
+	InterpDelay time.Duration // interpolation delay of the Unity client
+	MaxRewind   time.Duration // upper bound on how far back shots are judged
+
+	MapName string
+
+	// Explosion damage modifiers
+	SelfDamageMultiplier   float64 // damage to the thrower
+	FriendlyFireMultiplier float64 // damage to teammates of the thrower
+	OccludedDamageFactor   float64 // damage behind map geometry
+
+	// Team Deathmatch
+	KillLimit    int
+	TDMTimeLimit time.Duration
+	RespawnDelay time.Duration
+
+	// Duel
+	DuelBestOf int        // rounds, best of N
+	DuelWeapon WeaponType // the only weapon allowed
+
+	// Bounty Hunter
+	BountyPlayers    int
+	BountyScoreLimit int
+	BountyTimeLimit  time.Duration
+
+	// Gold Rush
+	GoldTarget      int           // gold needed to win
+	GoldPerSecond   float64       // gold earned for every held zone
+	GoldCaptureTime time.Duration // time for one player to capture a zone
+	GoldTimeLimit   time.Duration
+
+	// Debug accepts client-reported "explosion_damage" (testing only)
+	Debug bool
+}
+
+func DefaultMatchConfig() MatchConfig {
+	return MatchConfig{
+		TeamSize:    1,
+		TickRate:    defaultTickRate,
+		InterpDelay: 100 * time.Millisecond,
+		MaxRewind:   200 * time.Millisecond,
+
+		MapName: defaultMapName,
+
+		SelfDamageMultiplier:   0.5,
+		FriendlyFireMultiplier: 0.33,
+		OccludedDamageFactor:   0.25,
+
+		KillLimit:    50,
+		TDMTimeLimit: 10 * time.Minute,
+		RespawnDelay: 5 * time.Second,
+
+		DuelBestOf: 5,
+		DuelWeapon: WeaponRevolver,
+
+		BountyPlayers:    4,
+		BountyScoreLimit: 20,
+		BountyTimeLimit:  10 * time.Minute,
+
+		GoldTarget:      1000,
+		GoldPerSecond:   5,
+		GoldCaptureTime: 8 * time.Second,
+		GoldTimeLimit:   15 * time.Minute,
+	}
+}
+
+// Limiti sulla dimensione dei team
+const (
+	minTeamSize = 1
+	maxTeamSize = 5
+)
+
 type Match struct {
-	p1           *actor.PID
-	p2           *actor.PID
-	gameMode     GameMode
+	config       MatchConfig
+	gameMap      *GameMap
+	mode         GameMode
 	currentRound int
-	maxRounds    int
-	roundTime    time.Duration
-	buyTime      time.Duration
 	phase        RoundPhase
 
 	// Team assignments
-	teams map[*actor.PID]Team
+	roster map[Team][]*actor.PID
+	teams  map[*actor.PID]Team
+
+	// Disconnected players: they still count for the ratings, and a team
+	// nobody is left in forfeits
+	leavers   map[Team][]*actor.PID
+	forfeited map[Team]bool
 
 	// Round state
 	roundStartTime time.Time
-	bombPlanted    bool
-	bombPlantTime  time.Time
-	bombDefused    bool
 
 	// Score tracking
 	lawmenScore  int
 	outlawsScore int
+	playerScores map[*actor.PID]int
+
+	// Matchmaking rating of every player
+	ratings map[*actor.PID]int
 
 	// Player states per round
 	playersAlive map[*actor.PID]bool
@@ -64,8 +177,33 @@ type Match struct {
 	playerWeapons map[*actor.PID]*PlayerWeapons
 	playerMoney   map[*actor.PID]int
 
+	// Authoritative movement state, fed by "move"
+	playerStates map[*actor.PID]*PlayerState
+
+	// Simulation tick
+	ticker        *actor.SendRepeater
+	tick          int
+	pendingMoves  map[*actor.PID][]queuedMove
+	moveCorrected map[*actor.PID]bool
+	snapshots     map[*actor.PID]*snapshotHistory
+
+	// Timers scheduled with after, fired by the tick
+	timers          map[int]*matchTimer
+	nextTimerID     int
+	timerGeneration int
+	lastTimerSync   time.Time
+
+	// Lag compensation
+	positionHistory map[*actor.PID]*positionHistory
+	playerRTT       map[*actor.PID]time.Duration
+
 	// Explosion tracking
 	activeExplosions []Explosion
+
+	// Thrown dynamite, simulated by the tick
+	projectiles      []*Projectile
+	nextProjectileID int
+	lastThrow        map[*actor.PID]time.Time
 }
 
 type Explosion struct {
@@ -74,6 +212,11 @@ type Explosion struct {
 	Damage   int
 	Time     time.Time
 	OwnerPID *actor.PID
+
+	// Lingering fire after the blast
+	Linger     time.Duration
+	BurnDamage int // per second, within half the radius
+	nextBurn   time.Time
 }
 
 type ShootData struct {
@@ -88,43 +231,59 @@ type ExplosionData struct {
 	Damage   int
 }
 
-func NewMatch(p1, p2 *actor.PID) actor.Producer {
+// NewMatch spawns a match between the teams of roster: Lawmen and Outlaws,
+// or one SoloTeam per player in free-for-all modes. ratings are the
+// matchmaking ratings of the players, shown in "match_joined".
+func NewMatch(roster map[Team][]*actor.PID, ratings map[*actor.PID]int, config MatchConfig) actor.Producer {
 	return func() actor.Receiver {
-		teams := make(map[*actor.PID]Team)
-		teams[p1] = TeamLawmen
-		teams[p2] = TeamOutlaws
+		if rate := clampTickRate(config.TickRate); rate != config.TickRate {
+			log.Printf("Tick rate %d non supportato, uso %d", config.TickRate, rate)
+			config.TickRate = rate
+		}
 
+		teams := make(map[*actor.PID]Team)
 		playersAlive := make(map[*actor.PID]bool)
-		playersAlive[p1] = true
-		playersAlive[p2] = true
-
 		playerHealth := make(map[*actor.PID]int)
-		playerHealth[p1] = 100
-		playerHealth[p2] = 100
-
-		// Initialize weapon systems
 		playerWeapons := make(map[*actor.PID]*PlayerWeapons)
-		playerWeapons[p1] = NewPlayerWeapons()
-		playerWeapons[p2] = NewPlayerWeapons()
-
 		playerMoney := make(map[*actor.PID]int)
-		playerMoney[p1] = 800
-		playerMoney[p2] = 800
+
+		for team, players := range roster {
+			for _, pid := range players {
+				teams[pid] = team
+				playersAlive[pid] = true
+				playerHealth[pid] = 100
+				playerWeapons[pid] = NewPlayerWeapons() // Initialize weapon systems
+				playerMoney[pid] = 800
+			}
+		}
 
 		return &Match{
-			p1:            p1,
-			p2:            p2,
-			gameMode:      SearchAndDestroy,
+			config:        config,
+			gameMap:       GetMap(config.MapName),
+			mode:          newGameMode(config.Mode, config),
 			currentRound:  1,
-			maxRounds:     16,
-			roundTime:     time.Minute * 2,
-			buyTime:       time.Second * 15,
 			phase:         PhaseWarmup,
+			roster:        roster,
 			teams:         teams,
 			playersAlive:  playersAlive,
 			playerHealth:  playerHealth,
 			playerWeapons: playerWeapons,
 			playerMoney:   playerMoney,
+			playerScores:  make(map[*actor.PID]int),
+			ratings:       ratings,
+			playerStates:  make(map[*actor.PID]*PlayerState),
+			pendingMoves:  make(map[*actor.PID][]queuedMove),
+			moveCorrected: make(map[*actor.PID]bool),
+			snapshots:     make(map[*actor.PID]*snapshotHistory),
+			timers:        make(map[int]*matchTimer),
+
+			positionHistory: make(map[*actor.PID]*positionHistory),
+			playerRTT:       make(map[*actor.PID]time.Duration),
+
+			lastThrow: make(map[*actor.PID]time.Time),
+
+			leavers:   make(map[Team][]*actor.PID),
+			forfeited: make(map[Team]bool),
 		}
 	}
 }
@@ -132,166 +291,171 @@ func NewMatch(p1, p2 *actor.PID) actor.Producer {
 func (m *Match) Receive(c *actor.Context) {
 	switch msg := c.Message().(type) {
 	case actor.Started:
-		log.Printf("Western Showdown iniziato tra %s (Lawmen) e %s (Outlaws)",
-			m.p1.String(), m.p2.String())
-
-		m.sendToPlayer(c, m.p1, map[string]interface{}{
-			"action": "match_joined",
-			"team":   "lawmen",
-			"mode":   "search_destroy",
-		})
-
-		m.sendToPlayer(c, m.p2, map[string]interface{}{
-			"action": "match_joined",
-			"team":   "outlaws",
-			"mode":   "search_destroy",
-		})
-
-		go func() {
-			time.Sleep(5 * time.Second)
-			c.Send(c.PID(), "start_round")
-		}()
+		log.Printf("Western Showdown iniziato: %s, %d giocatori in %d squadre",
+			m.mode.Type(), len(m.teams), len(m.roster))
+
+		rosterData := make(map[string][]string, len(m.roster))
+		for team, players := range m.roster {
+			rosterData[m.getTeamName(team)] = playerIDs(players)
+		}
+		ratingData := make(map[string]int, len(m.ratings))
+		for pid, rating := range m.ratings {
+			ratingData[playerID(pid)] = rating
+		}
+		for pid, team := range m.teams {
+			c.Send(pid, &MatchJoined{
+				Match:    c.PID(),
+				Team:     team,
+				Mode:     m.mode.Type(),
+				PlayerID: playerID(pid),
+				Rating:   m.ratings[pid],
+				Roster:   rosterData,
+				Ratings:  ratingData,
+			})
+		}
+
+		m.startTicker(c)
+		m.mode.OnMatchStart(m, c)
+
+	case actor.Stopped:
+		m.stopTicker()
 
 	case string:
-		switch msg {
-		case "start_round":
-			m.startNewRound(c)
-		case "end_buy_time":
-			m.endBuyTime(c)
-		case "round_timer":
-			m.checkRoundTimer(c)
-		case "bomb_exploded":
-			m.endRound(c, TeamOutlaws, "Bomb exploded")
+		if msg == "tick" {
+			m.onTick(c)
 		}
 
 	case *PlayerAction:
 		m.handlePlayerAction(c, msg)
+
+	case *PlayerLeft:
+		m.removePlayer(c, msg.PID)
 	}
 }
 
-func (m *Match) startNewRound(c *actor.Context) {
-	m.phase = PhaseBuyTime
-	m.roundStartTime = time.Now()
-	m.bombPlanted = false
-	m.bombDefused = false
-	m.activeExplosions = []Explosion{}
+// removePlayer drops a player whose session ended: it dies where it stands,
+// the mode forgets it and so does the Match. A team left without players
+// forfeits, the match ends too when less than two players remain.
+func (m *Match) removePlayer(c *actor.Context, pid *actor.PID) {
+	team, ok := m.teams[pid]
+	if !ok || m.phase == PhaseEnd {
+		return
+	}
 
-	// Reset player states
-	for pid := range m.playersAlive {
-		m.playersAlive[pid] = true
-		m.playerHealth[pid] = 100
+	if m.phase == PhaseActive && m.playersAlive[pid] {
+		m.killPlayer(c, pid, nil, m.playerWeapons[pid].Current)
+	}
 
-		// Give money based on round result
-		if m.currentRound > 1 {
-			roundReward := GetRoundReward(false, false, false) // Base reward
-			m.playerMoney[pid] += roundReward
+	delete(m.teams, pid)
+	for i, member := range m.roster[team] {
+		if member == pid {
+			m.roster[team] = append(m.roster[team][:i:i], m.roster[team][i+1:]...)
+			break
 		}
 	}
-
-	roundData := map[string]interface{}{
-		"action":        "round_start",
-		"round":         m.currentRound,
-		"phase":         "buy_time",
-		"buy_time":      int(m.buyTime.Seconds()),
-		"time_limit":    int(m.roundTime.Seconds()),
-		"lawmen_score":  m.lawmenScore,
-		"outlaws_score": m.outlawsScore,
-		"money":         m.playerMoney,
+	m.leavers[team] = append(m.leavers[team], pid)
+
+	// The mode still sees the last state of the player, e.g. where it dropped the bomb
+	m.mode.OnPlayerLeft(m, c, pid)
+	delete(m.playersAlive, pid)
+	delete(m.playerHealth, pid)
+	delete(m.playerWeapons, pid)
+	delete(m.playerMoney, pid)
+	delete(m.playerStates, pid)
+	delete(m.pendingMoves, pid)
+	delete(m.moveCorrected, pid)
+	delete(m.snapshots, pid)
+	delete(m.positionHistory, pid)
+	delete(m.playerRTT, pid)
+	delete(m.lastThrow, pid)
+
+	log.Printf("Giocatore %s uscito dal match", pid.String())
+	m.broadcast(c, &PlayerLeftMsg{PlayerID: playerID(pid)})
+
+	if len(m.roster[team]) == 0 {
+		m.forfeited[team] = true
+		if !team.IsSolo() || len(m.teams) < 2 {
+			m.endMatch(c)
+			return
+		}
 	}
-
-	m.sendToPlayer(c, m.p1, roundData)
-	m.sendToPlayer(c, m.p2, roundData)
-
-	log.Printf("Round %d iniziato - Buy Phase - Lawmen: %d, Outlaws: %d",
-		m.currentRound, m.lawmenScore, m.outlawsScore)
-
-	// Buy time timer
-	go func() {
-		time.Sleep(m.buyTime)
-		c.Send(c.PID(), "end_buy_time")
-	}()
+	m.mode.CheckWinCondition(m, c)
 }
 
-func (m *Match) endBuyTime(c *actor.Context) {
-	m.phase = PhaseActive
+// startRound resets what every mode resets between rounds, puts the players
+// back on their spawns and hands over to the mode.
+func (m *Match) startRound(c *actor.Context) {
+	m.roundStartTime = time.Now()
+	m.activeExplosions = []Explosion{}
+	m.projectiles = nil
 
-	roundData := map[string]interface{}{
-		"action": "buy_time_end",
-		"phase":  "active",
+	// Reset player states
+	for pid := range m.teams {
+		m.playersAlive[pid] = true
+		m.playerHealth[pid] = 100
+	}
+	for team := range m.roster {
+		m.spawnTeam(c, team)
 	}
 
-	m.sendToPlayer(c, m.p1, roundData)
-	m.sendToPlayer(c, m.p2, roundData)
-
-	// Start round timer
-	go func() {
-		time.Sleep(m.roundTime)
-		c.Send(c.PID(), "round_timer")
-	}()
+	m.mode.OnRoundStart(m, c)
 }
 
 func (m *Match) handlePlayerAction(c *actor.Context, action *PlayerAction) {
-	var actionData map[string]interface{}
-	if err := json.Unmarshal([]byte(action.Data), &actionData); err != nil {
-		log.Printf("Errore parsing azione: %v", err)
+	name := action.Message.ActionName()
+	if ok, reason := m.mode.AllowAction(m, c, action.From, name); !ok {
+		m.rejectAction(c, action.From, name, reason)
 		return
 	}
 
-	switch action.Action {
-	case "buy_weapon":
-		if m.phase == PhaseBuyTime {
-			m.handleBuyWeapon(c, action.From, actionData)
-		}
-	case "shoot":
+	switch msg := action.Message.(type) {
+	case *ShootMsg:
 		if m.phase == PhaseActive {
-			m.handleAdvancedShoot(c, action.From, actionData)
+			m.handleAdvancedShoot(c, action.From, msg)
 		}
-	case "explosion_damage":
+	case *ThrowDynamiteMsg:
 		if m.phase == PhaseActive {
-			m.handleExplosionDamage(c, action.From, actionData)
+			m.handleThrowDynamite(c, action.From, msg)
 		}
-	case "plant_bomb":
-		if m.phase == PhaseActive {
-			m.handleBombPlant(c, action.From, actionData)
+	case *ExplosionDamageMsg:
+		// Damage and radius come from the client: never trust them in production
+		if m.config.Debug && m.phase == PhaseActive {
+			m.handleExplosionDamage(c, action.From, msg)
 		}
-	case "defuse_bomb":
-		if m.phase == PhaseActive {
-			m.handleBombDefuse(c, action.From, actionData)
-		}
-	case "move":
-		m.handleMove(c, action.From, actionData)
+	case *MoveMsg:
+		m.handleMove(c, action.From, msg)
+	case *SnapshotAckMsg:
+		m.handleSnapshotAck(action.From, msg)
 	default:
-		log.Printf("Azione non gestita: %s", action.Action)
+		if !m.mode.OnObjectiveAction(m, c, action.From, msg) {
+			log.Printf("Azione non gestita: %s", name)
+		}
 	}
 }
 
-func (m *Match) handleBuyWeapon(c *actor.Context, buyer *actor.PID, data map[string]interface{}) {
-	weaponTypeFloat, ok := data["weapon_type"].(float64)
-	if !ok {
-		return
-	}
-
-	weaponType := WeaponType(int(weaponTypeFloat))
+func (m *Match) handleBuyWeapon(c *actor.Context, buyer *actor.PID, msg *BuyWeaponMsg) {
+	weaponType := *msg.WeaponType
 	weapon := WeaponStats[weaponType]
 
-	if weapon == nil {
-		return
-	}
+	playerWeapons := m.playerWeapons[buyer]
 
 	// Check if player has enough money
 	if m.playerMoney[buyer] < weapon.Price {
-		m.sendToPlayer(c, buyer, map[string]interface{}{
-			"action": "buy_failed",
-			"reason": "insufficient_funds",
-		})
+		m.sendToPlayer(c, buyer, &BuyFailedMsg{Reason: "insufficient_funds"})
+		return
+	}
+	if weaponType == WeaponDynamite && playerWeapons.Dynamite >= maxDynamite {
+		m.sendToPlayer(c, buyer, &BuyFailedMsg{Reason: "dynamite_full"})
 		return
 	}
 
 	// Purchase weapon
 	m.playerMoney[buyer] -= weapon.Price
-	playerWeapons := m.playerWeapons[buyer]
 
-	if weaponType == WeaponRevolver {
+	if weaponType == WeaponDynamite {
+		// Non sostituisce la primaria
+		playerWeapons.Dynamite++
+	} else if weaponType == WeaponRevolver {
 		playerWeapons.Secondary = weaponType
 		playerWeapons.SecondaryAmmo = weapon.AmmoCapacity
 	} else {
@@ -299,16 +463,15 @@ func (m *Match) handleBuyWeapon(c *actor.Context, buyer *actor.PID, data map[str
 		playerWeapons.PrimaryAmmo = weapon.AmmoCapacity
 	}
 
-	m.sendToPlayer(c, buyer, map[string]interface{}{
-		"action":      "buy_success",
-		"weapon_type": weaponType,
-		"money":       m.playerMoney[buyer],
+	m.sendToPlayer(c, buyer, &BuySuccessMsg{
+		WeaponType: weaponType,
+		Money:      m.playerMoney[buyer],
 	})
 
 	log.Printf("Player %s bought weapon type %d for %d", buyer.String(), weaponType, weapon.Price)
 }
 
-func (m *Match) handleAdvancedShoot(c *actor.Context, shooter *actor.PID, data map[string]interface{}) {
+func (m *Match) handleAdvancedShoot(c *actor.Context, shooter *actor.PID, msg *ShootMsg) {
 	if !m.playersAlive[shooter] {
 		return
 	}
@@ -328,154 +491,134 @@ func (m *Match) handleAdvancedShoot(c *actor.Context, shooter *actor.PID, data m
 	// Get weapon data
 	weapon := playerWeapons.GetCurrentWeapon()
 
-	// Parse shoot data
-	originX, _ := data["originX"].(float64)
-	originY, _ := data["originY"].(float64)
-	originZ, _ := data["originZ"].(float64)
-	dirX, _ := data["dirX"].(float64)
-	dirY, _ := data["dirY"].(float64)
-	dirZ, _ := data["dirZ"].(float64)
-
-	shootOrigin := Position{X: originX, Y: originY, Z: originZ}
-	shootDirection := Position{X: dirX, Y: dirY, Z: dirZ}
-
-	_ = shootOrigin
-	_ = shootDirection
-	// Determine target
-	var target *actor.PID
-	if shooter == m.p1 {
-		target = m.p2
-	} else {
-		target = m.p1
+	shootOrigin := m.validateShotOrigin(shooter, msg.Origin())
+	shootDirection := msg.Direction().Normalize()
+
+	// Judge the shot against where the shooter saw its enemies and keep the
+	// closest impact: bullets do not go through players
+	var (
+		target       *actor.PID
+		hitDistance  float64
+		hitHeadshot  bool
+		viewTime     = m.rewindTime(shooter, time.Now())
+		traceMaxDist = weapon.Range * 3 // Beyond Range the damage falls off
+	)
+	if shootDirection.Length() > 0 {
+		for _, enemy := range m.enemiesOf(shooter) {
+			if !m.playersAlive[enemy] {
+				continue
+			}
+			pos, stance, known := m.rewoundPosition(enemy, viewTime)
+			if !known {
+				continue
+			}
+			distance, isHeadshot, hit := TraceShot(shootOrigin, shootDirection, traceMaxDist, pos, stance)
+			if hit && (target == nil || distance < hitDistance) {
+				target, hitDistance, hitHeadshot = enemy, distance, isHeadshot
+			}
+		}
 	}
 
-	if !m.playersAlive[target] {
-		return
+	// Un edificio prima dell'impatto ferma il colpo
+	if target != nil && m.gameMap.LineBlocked(shootOrigin, shootOrigin.Add(shootDirection.Scale(hitDistance))) {
+		target = nil
 	}
 
-	// Calculate hit probability based on weapon and distance
-	// For simplicity, assume some distance (in real game, get from positions)
-	distance := 10.0 // Placeholder
-	hitChance := calculateHitChance(weapon, distance)
-
-	// Simulate hit
-	if rand.Float64() <= hitChance {
-		// Calculate damage
-		isHeadshot := rand.Float64() < 0.15 // 15% headshot chance
-		damage := CalculateDamage(weapon, distance, isHeadshot)
-
-		// Apply damage
-		m.playerHealth[target] -= damage
-
-		// Award money for hit
-		m.playerMoney[shooter] += GetKillReward(playerWeapons.Current)
-
-		// Send hit confirmation to shooter
-		m.sendToPlayer(c, shooter, map[string]interface{}{
-			"action":   "hit_confirmed",
-			"damage":   damage,
-			"headshot": isHeadshot,
-		})
-
-		// Send damage to target
-		m.sendToPlayer(c, target, map[string]interface{}{
-			"action":   "hit",
-			"damage":   damage,
-			"health":   m.playerHealth[target],
-			"headshot": isHeadshot,
-		})
-
-		// Check if player died
-		if m.playerHealth[target] <= 0 {
-			m.playersAlive[target] = false
-
-			m.sendToPlayer(c, target, map[string]interface{}{
-				"action": "player_died",
-			})
+	if target != nil {
+		m.applyShotHit(c, shooter, target, weapon, hitDistance, hitHeadshot)
+	}
 
-			m.sendToPlayer(c, shooter, map[string]interface{}{
-				"action": "enemy_killed",
-				"money":  m.playerMoney[shooter],
-			})
+	// Forward shoot action to the other players for visual effects
+	m.broadcastExcept(c, shooter, &EnemyShootMsg{
+		Shooter:    playerID(shooter),
+		OriginX:    shootOrigin.X,
+		OriginY:    shootOrigin.Y,
+		OriginZ:    shootOrigin.Z,
+		DirX:       shootDirection.X,
+		DirY:       shootDirection.Y,
+		DirZ:       shootDirection.Z,
+		WeaponType: playerWeapons.Current,
+	})
+}
 
-			m.checkRoundEndConditions(c)
-		}
+// validateShotOrigin returns the client origin if it is close to the shooter's
+// eyes as tracked by the server, otherwise the server-side eye position.
+func (m *Match) validateShotOrigin(shooter *actor.PID, clientOrigin Position) Position {
+	state, ok := m.playerStates[shooter]
+	if !ok {
+		return clientOrigin
 	}
-
-	// Forward shoot action to other player for visual effects
-	shootData := map[string]interface{}{
-		"action":      "enemy_shoot",
-		"originX":     originX,
-		"originY":     originY,
-		"originZ":     originZ,
-		"dirX":        dirX,
-		"dirY":        dirY,
-		"dirZ":        dirZ,
-		"weapon_type": playerWeapons.Current,
+	eye := EyePosition(state.Position, state.Stance)
+	if eye.Distance(clientOrigin) > maxShotOriginOffset {
+		return eye
 	}
-
-	m.sendToPlayer(c, target, shootData)
+	return clientOrigin
 }
 
-func (m *Match) handleExplosionDamage(c *actor.Context, exploder *actor.PID, data map[string]interface{}) {
-	posX, _ := data["x"].(float64)
-	posY, _ := data["y"].(float64)
-	posZ, _ := data["z"].(float64)
-	radius, _ := data["radius"].(float64)
-	damage, _ := data["damage"].(float64)
+func (m *Match) applyShotHit(c *actor.Context, shooter, target *actor.PID, weapon *Weapon, distance float64, isHeadshot bool) {
+	playerWeapons := m.playerWeapons[shooter]
+	damage := CalculateDamage(weapon, distance, isHeadshot)
 
-	explosion := Explosion{
-		Position: Position{X: posX, Y: posY, Z: posZ},
-		Radius:   radius,
-		Damage:   int(damage),
-		Time:     time.Now(),
-		OwnerPID: exploder,
-	}
+	// Apply damage
+	m.playerHealth[target] -= damage
 
-	m.activeExplosions = append(m.activeExplosions, explosion)
+	// Award money for hit
+	m.playerMoney[shooter] += GetKillReward(playerWeapons.Current)
 
-	// Check damage to all players
-	for pid := range m.playersAlive {
-		if !m.playersAlive[pid] {
-			continue
-		}
+	// Send hit confirmation to shooter
+	m.sendToPlayer(c, shooter, &HitConfirmedMsg{
+		Damage:   damage,
+		Headshot: isHeadshot,
+		Distance: distance,
+	})
 
-		// For simplicity, assume player is within explosion radius
-		// In real implementation, check actual distance
-		distance := 5.0 // Placeholder
+	// Send damage to target
+	m.sendToPlayer(c, target, &HitMsg{
+		Damage:   damage,
+		Health:   m.playerHealth[target],
+		Headshot: isHeadshot,
+	})
 
-		if distance <= radius {
-			// Calculate damage based on distance
-			damageMultiplier := math.Max(0.1, 1.0-(distance/radius))
-			actualDamage := int(float64(damage) * damageMultiplier)
+	m.mode.OnPlayerHit(m, c, shooter, target)
 
-			m.playerHealth[pid] -= actualDamage
+	// Check if player died
+	if m.playerHealth[target] <= 0 {
+		m.killPlayer(c, target, shooter, weapon.Type)
+		m.mode.CheckWinCondition(m, c)
+	}
+}
 
-			m.sendToPlayer(c, pid, map[string]interface{}{
-				"action": "explosion_damage",
-				"damage": actualDamage,
-				"health": m.playerHealth[pid],
-			})
+// killPlayer marks victim as dead and notifies both sides. killer may be the
+// victim itself (self-inflicted) or nil; weapon is what dealt the last blow.
+func (m *Match) killPlayer(c *actor.Context, victim, killer *actor.PID, weapon WeaponType) {
+	m.playersAlive[victim] = false
 
-			if m.playerHealth[pid] <= 0 {
-				m.playersAlive[pid] = false
-				m.sendToPlayer(c, pid, map[string]interface{}{
-					"action": "player_died",
-				})
-
-				// Award kill to exploder
-				if exploder != pid {
-					m.playerMoney[exploder] += GetKillReward(WeaponDynamite)
-					m.sendToPlayer(c, exploder, map[string]interface{}{
-						"action": "enemy_killed",
-						"money":  m.playerMoney[exploder],
-					})
-				}
-			}
-		}
+	m.sendToPlayer(c, victim, &PlayerDiedMsg{})
+
+	if killer != nil && killer != victim {
+		m.sendToPlayer(c, killer, &EnemyKilledMsg{Money: m.playerMoney[killer]})
+	}
+
+	m.mode.OnPlayerKilled(m, c, victim, killer, weapon)
+}
+
+// rejectAction tells player that action is not allowed right now.
+func (m *Match) rejectAction(c *actor.Context, player *actor.PID, action, reason string) {
+	m.sendToPlayer(c, player, &ActionRejectedMsg{Rejected: action, Reason: reason})
+}
+
+func (m *Match) handleExplosionDamage(c *actor.Context, exploder *actor.PID, msg *ExplosionDamageMsg) {
+	explosion := Explosion{
+		Position:   Position{X: msg.X, Y: msg.Y, Z: msg.Z},
+		Radius:     msg.Radius,
+		Damage:     msg.Damage,
+		Time:       time.Now(),
+		OwnerPID:   exploder,
+		Linger:     dynamiteLinger,
+		BurnDamage: dynamiteBurnDamage,
 	}
 
-	m.checkRoundEndConditions(c)
+	m.detonate(c, explosion)
 }
 
 type Position struct {
@@ -484,218 +627,208 @@ type Position struct {
 	Z float64 `json:"z"`
 }
 
-func (m *Match) handleMove(c *actor.Context, mover *actor.PID, data map[string]interface{}) {
-	// Forward movement to other player
-	var recipient *actor.PID
-	if mover == m.p1 {
-		recipient = m.p2
-	} else {
-		recipient = m.p1
+// handleMove queues the input; it is validated and applied on the next tick.
+func (m *Match) handleMove(c *actor.Context, mover *actor.PID, msg *MoveMsg) {
+	if !m.playersAlive[mover] {
+		return
 	}
 
-	moveData := data
-	moveData["action"] = "enemy_move"
-	m.sendToPlayer(c, recipient, moveData)
+	m.pendingMoves[mover] = append(m.pendingMoves[mover], queuedMove{
+		input:      msg.Input(),
+		receivedAt: time.Now(),
+	})
 }
 
-func calculateHitChance(weapon *Weapon, distance float64) float64 {
-	// Base hit chance varies by weapon
-	baseChance := 0.8
-
-	switch weapon.Type {
-	case WeaponRevolver:
-		baseChance = 0.75
-	case WeaponShotgun:
-		baseChance = 0.9 // High close range accuracy
-	case WeaponRifle:
-		baseChance = 0.85
-	case WeaponDynamite:
-		baseChance = 1.0 // Always "hits" but may not damage
-	}
-
-	// Reduce chance based on distance and weapon range
-	if distance > weapon.Range {
-		distancePenalty := (distance - weapon.Range) / weapon.Range
-		baseChance *= math.Max(0.1, 1.0-distancePenalty)
+// aliveCount returns how many players of team are alive.
+func (m *Match) aliveCount(team Team) int {
+	alive := 0
+	for _, pid := range m.roster[team] {
+		if m.playersAlive[pid] {
+			alive++
+		}
 	}
-
-	// Factor in weapon spread
-	spreadPenalty := weapon.Spread / 10.0
-	baseChance *= math.Max(0.3, 1.0-spreadPenalty)
-
-	return math.Min(1.0, math.Max(0.1, baseChance))
+	return alive
 }
 
-func (m *Match) checkRoundEndConditions(c *actor.Context) {
-	lawmenAlive := 0
-	outlawsAlive := 0
+func (m *Match) endMatch(c *actor.Context) {
+	m.phase = PhaseEnd
 
-	for pid, alive := range m.playersAlive {
-		if alive {
-			if m.teams[pid] == TeamLawmen {
-				lawmenAlive++
-			} else {
-				outlawsAlive++
+	winnerName := m.mode.MatchWinner(m)
+	if len(m.forfeited) > 0 && !m.mode.Type().IsFreeForAll() {
+		// Vince la squadra rimasta in campo
+		for team := range m.roster {
+			if !m.forfeited[team] {
+				winnerName = m.getTeamName(team)
 			}
 		}
 	}
 
-	if lawmenAlive == 0 {
-		m.endRound(c, TeamOutlaws, "All lawmen eliminated")
-	} else if outlawsAlive == 0 {
-		m.endRound(c, TeamLawmen, "All outlaws eliminated")
+	playerScores := make(map[string]int, len(m.teams))
+	for pid := range m.teams {
+		playerScores[playerID(pid)] = m.playerScores[pid]
 	}
-}
 
-func (m *Match) endRound(c *actor.Context, winner Team, reason string) {
-	m.phase = PhaseEnd
+	m.cancelRoundTimers(c)
+	m.broadcast(c, &MatchEndMsg{
+		Winner: winnerName,
+		FinalScore: map[string]int{
+			"lawmen":  m.lawmenScore,
+			"outlaws": m.outlawsScore,
+		},
+		PlayerScores: playerScores,
+	})
 
-	// Award round money
-	roundReward := GetRoundReward(true, m.bombDefused, m.bombPlanted)
-	loseReward := GetRoundReward(false, false, false)
+	log.Printf("Match terminato - Vincitore: %s (%d-%d)",
+		winnerName, m.lawmenScore, m.outlawsScore)
 
-	for pid := range m.playerMoney {
-		if m.teams[pid] == winner {
-			m.playerMoney[pid] += roundReward
-		} else {
-			m.playerMoney[pid] += loseReward
-		}
-	}
+	m.stopTicker()
 
-	// Update score
-	if winner == TeamLawmen {
-		m.lawmenScore++
-	} else {
-		m.outlawsScore++
+	for pid := range m.teams {
+		c.Send(pid, &MatchLeft{Match: c.PID(), Reason: "match_end"})
 	}
 
-	endData := map[string]interface{}{
-		"action":        "round_end",
-		"winner":        m.getTeamName(winner),
-		"reason":        reason,
-		"lawmen_score":  m.lawmenScore,
-		"outlaws_score": m.outlawsScore,
-		"money":         m.playerMoney,
+	// Il matchmaking aggiorna i rating, anche di chi è uscito prima
+	result := &MatchResult{
+		Mode:   m.mode.Type(),
+		Teams:  make(map[Team][]*actor.PID, len(m.roster)),
+		Scores: make(map[Team]int, len(m.roster)),
 	}
-
-	m.sendToPlayer(c, m.p1, endData)
-	m.sendToPlayer(c, m.p2, endData)
-
-	log.Printf("Round %d terminato - Vincitore: %s (%s)",
-		m.currentRound, m.getTeamName(winner), reason)
-
-	if m.lawmenScore >= 9 || m.outlawsScore >= 9 {
-		m.endMatch(c)
-	} else {
-		m.currentRound++
-		go func() {
-			time.Sleep(5 * time.Second)
-			c.Send(c.PID(), "start_round")
-		}()
+	for team, players := range m.roster {
+		result.Teams[team] = append(append([]*actor.PID(nil), players...), m.leavers[team]...)
+		result.Scores[team] = m.teamScore(team)
+		if m.forfeited[team] {
+			result.Scores[team] = -1
+		}
 	}
+	c.Send(c.Parent(), result)
+
+	// Finito il match l'attore non serve più
+	c.Engine().Poison(c.PID())
 }
 
-// Rest of the methods remain the same as original...
-func (m *Match) handleBombPlant(c *actor.Context, planter *actor.PID, data map[string]interface{}) {
-	if m.teams[planter] != TeamOutlaws || m.bombPlanted {
-		return
+// teamScore ranks team at the end of the match: rounds, kills or gold for
+// Lawmen and Outlaws, the player score for solo teams.
+func (m *Match) teamScore(team Team) int {
+	switch team {
+	case TeamLawmen:
+		return m.lawmenScore
+	case TeamOutlaws:
+		return m.outlawsScore
 	}
+	score := 0
+	for _, pid := range m.roster[team] {
+		score += m.playerScores[pid]
+	}
+	return score
+}
 
-	m.bombPlanted = true
-	m.bombPlantTime = time.Now()
+// sendToPlayer hands msg to the PlayerSession of player, which encodes it.
+// msg is shared between recipients and must not be modified afterwards.
+func (m *Match) sendToPlayer(c *actor.Context, player *actor.PID, msg ServerMessage) {
+	c.Send(player, msg)
+}
 
-	plantData := map[string]interface{}{
-		"action":     "bomb_planted",
-		"planted_by": m.getPlayerName(planter),
+// sendSnapshot sends world to player as a delta against the last snapshot the
+// player acknowledged, or in full when that ack is missing or too old.
+func (m *Match) sendSnapshot(c *actor.Context, player *actor.PID, world WorldState) {
+	history, ok := m.snapshots[player]
+	if !ok {
+		history = &snapshotHistory{}
+		m.snapshots[player] = history
 	}
 
-	m.sendToPlayer(c, m.p1, plantData)
-	m.sendToPlayer(c, m.p2, plantData)
+	seq := m.tick
+	snapshot := &SnapshotMsg{
+		Seq:       seq,
+		Self:      playerID(player),
+		Corrected: m.moveCorrected[player],
+	}
 
-	log.Printf("Bomba piazzata da %s", planter.String())
+	if base, ok := history.baseline(seq); ok {
+		snapshot.Base = base.seq
+		snapshot.Entities, snapshot.Removed, snapshot.RemovedFields = world.Diff(base.state)
+	} else {
+		snapshot.Full = true
+		snapshot.Entities = world
+	}
 
-	go func() {
-		time.Sleep(45 * time.Second)
-		if m.bombPlanted && !m.bombDefused {
-			c.Send(c.PID(), "bomb_exploded")
-		}
-	}()
+	history.record(seq, world, time.Now())
+	m.sendToPlayer(c, player, snapshot)
 }
 
-func (m *Match) handleBombDefuse(c *actor.Context, defuser *actor.PID, data map[string]interface{}) {
-	if m.teams[defuser] != TeamLawmen || !m.bombPlanted || m.bombDefused {
+func (m *Match) handleSnapshotAck(player *actor.PID, msg *SnapshotAckMsg) {
+	seq := *msg.Seq
+	history, ok := m.snapshots[player]
+	if !ok || !history.ack(seq) {
 		return
 	}
-
-	m.bombDefused = true
-
-	defuseData := map[string]interface{}{
-		"action":     "bomb_defused",
-		"defused_by": m.getPlayerName(defuser),
+	if sent, ok := history.lookup(seq); ok {
+		m.updateRTT(player, time.Since(sent.sentAt))
 	}
-
-	m.sendToPlayer(c, m.p1, defuseData)
-	m.sendToPlayer(c, m.p2, defuseData)
-
-	log.Printf("Bomba disinnescata da %s", defuser.String())
-
-	m.endRound(c, TeamLawmen, "Bomb defused")
 }
 
-func (m *Match) checkRoundTimer(c *actor.Context) {
-	if m.phase != PhaseActive {
-		return
+// broadcast sends msg to every player of the match.
+func (m *Match) broadcast(c *actor.Context, msg ServerMessage) {
+	for pid := range m.teams {
+		m.sendToPlayer(c, pid, msg)
 	}
+}
 
-	if m.bombPlanted && !m.bombDefused {
-		m.endRound(c, TeamOutlaws, "Bomb exploded")
-	} else {
-		m.endRound(c, TeamLawmen, "Time expired")
+// broadcastTeam sends msg to the players of team only.
+func (m *Match) broadcastTeam(c *actor.Context, team Team, msg ServerMessage) {
+	for _, pid := range m.roster[team] {
+		m.sendToPlayer(c, pid, msg)
 	}
 }
 
-func (m *Match) endMatch(c *actor.Context) {
-	var winner Team
-	if m.lawmenScore > m.outlawsScore {
-		winner = TeamLawmen
-	} else {
-		winner = TeamOutlaws
+// broadcastWithTeamMoney sends every team its own message, built with the
+// money of the recipient's teammates keyed by player id: the enemy economy
+// stays hidden.
+func (m *Match) broadcastWithTeamMoney(c *actor.Context, build func(money map[string]int) ServerMessage) {
+	for team, players := range m.roster {
+		money := make(map[string]int, len(players))
+		for _, pid := range players {
+			money[playerID(pid)] = m.playerMoney[pid]
+		}
+		m.broadcastTeam(c, team, build(money))
 	}
+}
 
-	matchEndData := map[string]interface{}{
-		"action": "match_end",
-		"winner": m.getTeamName(winner),
-		"final_score": map[string]int{
-			"lawmen":  m.lawmenScore,
-			"outlaws": m.outlawsScore,
-		},
+// broadcastExcept sends msg to everyone but excluded.
+func (m *Match) broadcastExcept(c *actor.Context, excluded *actor.PID, msg ServerMessage) {
+	for pid := range m.teams {
+		if pid != excluded {
+			m.sendToPlayer(c, pid, msg)
+		}
 	}
+}
 
-	m.sendToPlayer(c, m.p1, matchEndData)
-	m.sendToPlayer(c, m.p2, matchEndData)
+// enemiesOf returns the players on every team other than pid's.
+func (m *Match) enemiesOf(pid *actor.PID) []*actor.PID {
+	var enemies []*actor.PID
+	for team, players := range m.roster {
+		if team != m.teams[pid] {
+			enemies = append(enemies, players...)
+		}
+	}
+	return enemies
+}
 
-	log.Printf("Match terminato - Vincitore: %s (%d-%d)",
-		m.getTeamName(winner), m.lawmenScore, m.outlawsScore)
+// playerID identifies a player towards clients.
+func playerID(pid *actor.PID) string {
+	return pid.String()
 }
 
-func (m *Match) sendToPlayer(c *actor.Context, player *actor.PID, data map[string]interface{}) {
-	jsonData, _ := json.Marshal(data)
-	c.Send(player, &PlayerAction{
-		From:   c.PID(),
-		Action: data["action"].(string),
-		Data:   string(jsonData),
-	})
+func playerIDs(pids []*actor.PID) []string {
+	ids := make([]string, len(pids))
+	for i, pid := range pids {
+		ids[i] = playerID(pid)
+	}
+	return ids
 }
 
 func (m *Match) getTeamName(team Team) string {
-	switch team {
-	case TeamLawmen:
-		return "lawmen"
-	case TeamOutlaws:
-		return "outlaws"
-	default:
-		return "unknown"
-	}
+	return team.String()
 }
 
 func (m *Match) getPlayerName(pid *actor.PID) string {
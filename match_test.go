@@ -0,0 +1,118 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// spawnMatch starts a Match under a parent that hands its MatchResult to
+// the test, like Matchmaking would receive it.
+func spawnMatch(t *testing.T, e *actor.Engine, roster map[Team][]*actor.PID, config MatchConfig) (*actor.PID, chan *MatchResult) {
+	t.Helper()
+	results := make(chan *MatchResult, 1)
+	pids := make(chan *actor.PID, 1)
+	e.SpawnFunc(func(c *actor.Context) {
+		switch msg := c.Message().(type) {
+		case actor.Started:
+			pids <- c.SpawnChild(NewMatch(roster, nil, config), "match")
+		case *MatchResult:
+			results <- msg
+		}
+	}, "lobby")
+	select {
+	case pid := <-pids:
+		return pid, results
+	case <-time.After(2 * time.Second):
+		t.Fatal("match not spawned")
+		return nil, nil
+	}
+}
+
+func isMatchEnd(msg ServerMessage) bool {
+	_, ok := msg.(*MatchEndMsg)
+	return ok
+}
+
+func TestPlayerLeftForfeitsDuel(t *testing.T) {
+	e := newTestEngine(t)
+	a, b := newProbe(e, "a"), newProbe(e, "b")
+
+	config := DefaultMatchConfig()
+	config.Mode = Duel
+	match, results := spawnMatch(t, e, map[Team][]*actor.PID{
+		TeamLawmen:  {a.pid},
+		TeamOutlaws: {b.pid},
+	}, config)
+
+	e.Send(match, &PlayerLeft{PID: b.pid})
+
+	left := a.expect(t, "player_left", func(msg ServerMessage) bool {
+		_, ok := msg.(*PlayerLeftMsg)
+		return ok
+	}).(*PlayerLeftMsg)
+	if left.PlayerID != playerID(b.pid) {
+		t.Errorf("player_left for %s, want %s", left.PlayerID, playerID(b.pid))
+	}
+	end := a.expect(t, "match_end", isMatchEnd).(*MatchEndMsg)
+	if end.Winner != TeamLawmen.String() {
+		t.Errorf("winner = %q, want %q", end.Winner, TeamLawmen.String())
+	}
+
+	select {
+	case result := <-results:
+		if got := result.Teams[TeamOutlaws]; len(got) != 1 || got[0] != b.pid {
+			t.Errorf("outlaws in result = %v, want the leaver", got)
+		}
+		if result.Scores[TeamOutlaws] >= result.Scores[TeamLawmen] {
+			t.Errorf("scores = %v, want the outlaws to lose", result.Scores)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no match result")
+	}
+}
+
+func TestPlayerLeftTeamPlaysOn(t *testing.T) {
+	e := newTestEngine(t)
+	a1, a2, b1, b2 := newProbe(e, "a1"), newProbe(e, "a2"), newProbe(e, "b1"), newProbe(e, "b2")
+
+	config := DefaultMatchConfig()
+	config.Mode = TeamDeathmatch
+	config.TeamSize = 2
+	match, results := spawnMatch(t, e, map[Team][]*actor.PID{
+		TeamLawmen:  {a1.pid, a2.pid},
+		TeamOutlaws: {b1.pid, b2.pid},
+	}, config)
+
+	// Resta un outlaw: si continua
+	e.Send(match, &PlayerLeft{PID: b2.pid})
+	a1.expect(t, "player_left", func(msg ServerMessage) bool {
+		_, ok := msg.(*PlayerLeftMsg)
+		return ok
+	})
+	select {
+	case <-results:
+		t.Fatal("match ended with players left on both teams")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if b2.got(isMatchEnd) {
+		t.Error("the leaver still receives match messages")
+	}
+
+	// Un leave ripetuto non conta due volte
+	e.Send(match, &PlayerLeft{PID: b2.pid})
+	e.Send(match, &PlayerLeft{PID: b1.pid})
+	a1.expect(t, "match_end", isMatchEnd)
+	select {
+	case result := <-results:
+		if len(result.Teams[TeamOutlaws]) != 2 {
+			t.Errorf("outlaws in result = %v, want both leavers", result.Teams[TeamOutlaws])
+		}
+		if result.Scores[TeamOutlaws] != -1 {
+			t.Errorf("outlaws score = %d, want the forfeit", result.Scores[TeamOutlaws])
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no match result")
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/anthdm/hollywood/actor"
@@ -9,51 +10,533 @@ import (
 
 type Matchmaking struct {
 	players map[string]*PlayerStatus
+	ratings map[string]int
+	config  MatchConfig
+	ticker  *actor.SendRepeater
+
+	// Ready check: match proposti in attesa di conferma
+	proposals      map[int]*matchProposal
+	nextProposalID int
+	cooldowns      map[string]time.Time // per identità: chi ha rifiutato non rientra subito
+	identities     map[string]string    // pid -> identità stabile della sessione
+
+	// Media mobile dell'attesa in coda, per la stima in "queue_status"
+	waitEstimate map[GameModeType]time.Duration
 }
 
-func NewMatchmaking() actor.Producer {
+// Intervallo del controllo periodico del matchmaking
+const matchmakingInterval = time.Second
+
+func NewMatchmaking(config MatchConfig) actor.Producer {
 	return func() actor.Receiver {
-		return &Matchmaking{players: make(map[string]*PlayerStatus)}
+		config.TeamSize = max(minTeamSize, min(maxTeamSize, config.TeamSize))
+		return &Matchmaking{
+			players: make(map[string]*PlayerStatus),
+			ratings: make(map[string]int),
+			config:  config,
+
+			proposals:  make(map[int]*matchProposal),
+			cooldowns:  make(map[string]time.Time),
+			identities: make(map[string]string),
+
+			waitEstimate: make(map[GameModeType]time.Duration),
+		}
 	}
 }
 
+// Receive owns every piece of matchmaking state: players are matched
+// right when they are enqueued and again on the periodic "match_tick".
 func (m *Matchmaking) Receive(c *actor.Context) {
 	switch msg := c.Message().(type) {
 
 	case actor.Started:
-		go m.MatchLoop(c)
+		ticker := c.Engine().SendRepeat(c.PID(), "match_tick", matchmakingInterval)
+		m.ticker = &ticker
+
+	case actor.Stopped:
+		if m.ticker != nil {
+			m.ticker.Stop()
+		}
+
+	case string:
+		if msg == "match_tick" {
+			m.expireProposals(c)
+			m.findMatches(c)
+			m.sendQueueStatus(c)
+		}
+
+	case *QueueRequest:
+		m.enqueue(c, msg)
+
+	case *SessionOnline:
+		m.identities[msg.PID.String()] = msg.Identity
+
+	case *QueueLeave:
+		m.leaveQueue(c, msg)
+		if msg.Disconnected {
+			delete(m.identities, msg.PID.String())
+		}
+
+	case *PartyChanged:
+		m.partyChanged(c, msg)
+
+	case *MatchResponse:
+		m.respond(c, msg.PID, msg.ProposalID, msg.Accept)
+
+	case *MatchResult:
+		m.updateRatings(msg)
+		m.afterMatch(c, msg)
+	}
+}
+
+// enqueue adds a player, or a whole party, to the queue of its mode, or
+// moves it to another mode while it is still waiting. Players in a match are
+// ignored.
+func (m *Matchmaking) enqueue(c *actor.Context, req *QueueRequest) {
+	members := req.Party
+	if len(members) == 0 {
+		members = []*actor.PID{req.PID}
+	}
+
+	// La squadra la sceglie la richiesta, altrimenti basta per tutto il party
+	teamSize := req.TeamSize
+	if teamSize == 0 {
+		teamSize = max(m.config.TeamSize, len(members))
+	}
+	key := queueKey{Mode: req.Mode, TeamSize: max(minTeamSize, min(maxTeamSize, teamSize))}
+	teams, teamSize := m.lineup(key)
+	key.TeamSize = teamSize
+
+	if len(members) > 1 {
+		if req.Mode.IsFreeForAll() || teams < 2 || len(members) > teamSize {
+			notifyPlayer(c, req.PID, &QueueStatusMsg{State: "rejected", Reason: "party_too_big_for_mode"})
+			return
+		}
+	}
+	for _, pid := range members {
+		if player, ok := m.players[pid.String()]; ok && !player.Free {
+			return
+		}
+		if until, ok := m.cooldowns[m.identity(pid)]; ok && time.Now().Before(until) {
+			notifyPlayer(c, req.PID, &QueueStatusMsg{
+				State:     "cooldown",
+				PlayerID:  playerID(pid),
+				Remaining: int(time.Until(until).Seconds()) + 1,
+			})
+			return
+		}
+	}
 
-	case *actor.PID:
-		// Aggiunta giocatore
-		m.players[msg.String()] = &PlayerStatus{PID: msg, Free: true}
-		fmt.Printf("Giocatore %s aggiunto al matchmaking\n", msg.String())
+	queuedAt := time.Now()
+	if player, ok := m.players[req.PID.String()]; ok && player.queueKey() == key {
+		// Stessa coda: si tiene il posto
+		queuedAt = player.QueuedAt
 	}
+	for _, pid := range members {
+		m.players[pid.String()] = &PlayerStatus{
+			PID:      pid,
+			Free:     true,
+			Mode:     req.Mode,
+			TeamSize: key.TeamSize,
+			Rating:   m.rating(pid),
+			QueuedAt: queuedAt,
+			Requeue:  req.Requeue,
+			PartyID:  req.PartyID,
+		}
+	}
+
+	fmt.Printf("Giocatore %s aggiunto al matchmaking (%s, squadre da %d, %d in gruppo)\n",
+		req.PID.String(), req.Mode, key.TeamSize, len(members))
+
+	m.findMatches(c)
+	m.sendQueueStatus(c)
 }
 
-func (m *Matchmaking) MatchLoop(c *actor.Context) {
-	for {
-		time.Sleep(1 * time.Second) // intervallo di controllo matchmaking
-		var p1, p2 *PlayerStatus
+// leaveQueue takes a player out of the queue, together with its party. A
+// player that disconnected during a match is forgotten as well, its result
+// is simply skipped.
+// identity keys the decline cooldowns: a new session of the same player
+// must not reset them.
+func (m *Matchmaking) identity(pid *actor.PID) string {
+	if id := m.identities[pid.String()]; id != "" {
+		return id
+	}
+	return pid.String()
+}
 
-		// Trova due giocatori liberi
-		for _, player := range m.players {
-			if player.Free {
-				if p1 == nil {
-					p1 = player
-					continue
+func (m *Matchmaking) leaveQueue(c *actor.Context, msg *QueueLeave) {
+	player, ok := m.players[msg.PID.String()]
+	if !ok {
+		return
+	}
+	if player.Proposal != 0 {
+		// Uscire durante il ready check vale come rifiuto
+		m.respond(c, msg.PID, player.Proposal, false)
+		player, ok = m.players[msg.PID.String()]
+		if !ok {
+			return
+		}
+	}
+	if !player.Free {
+		if msg.Disconnected {
+			delete(m.players, msg.PID.String())
+		}
+		return
+	}
+
+	for id, other := range m.players {
+		if other == player || (player.PartyID != 0 && other.PartyID == player.PartyID && other.Free) {
+			delete(m.players, id)
+			notifyPlayer(c, other.PID, &QueueStatusMsg{State: "left"})
+			fmt.Printf("Giocatore %s uscito dal matchmaking\n", other.PID.String())
+		}
+	}
+
+	m.sendQueueStatus(c)
+}
+
+// partyChanged takes the members of a changed party out of the queue: the
+// group that queued is not the party anymore, the leader queues it again.
+// It is not a decline: members in a ready check or in a match keep playing
+// with their old group and regroup once it is over.
+func (m *Matchmaking) partyChanged(c *actor.Context, msg *PartyChanged) {
+	partyOf := make(map[*actor.PID]int)
+	for _, pid := range msg.Members {
+		partyOf[pid] = msg.PartyID
+	}
+	for _, pid := range msg.Left {
+		partyOf[pid] = 0
+	}
+
+	for pid, partyID := range partyOf {
+		player, ok := m.players[pid.String()]
+		if !ok {
+			continue
+		}
+		player.PartyID = partyID
+		if !player.Free {
+			player.Regroup = true
+			continue
+		}
+		delete(m.players, pid.String())
+		notifyPlayer(c, pid, &QueueStatusMsg{State: "left", Reason: "party_changed"})
+		fmt.Printf("Giocatore %s uscito dal matchmaking: party cambiato\n", pid.String())
+	}
+
+	m.sendQueueStatus(c)
+}
+
+// afterMatch puts the players of a finished match back in the queue, or in
+// the lobby unless they asked to requeue. Whose party changed during the
+// match goes to the lobby too: the leader queues the new party.
+func (m *Matchmaking) afterMatch(c *actor.Context, result *MatchResult) {
+	for _, players := range result.Teams {
+		for _, pid := range players {
+			player, ok := m.players[pid.String()]
+			if !ok {
+				// Disconnesso durante il match
+				continue
+			}
+			if !player.Requeue || player.Regroup {
+				delete(m.players, pid.String())
+				status := &QueueStatusMsg{State: "lobby"}
+				if player.Regroup {
+					status.Reason = "party_changed"
 				}
-				p2 = player
+				notifyPlayer(c, pid, status)
+				continue
+			}
+			player.Free = true
+			player.Rating = m.rating(pid)
+			player.QueuedAt = time.Now()
+		}
+	}
+
+	m.findMatches(c)
+	m.sendQueueStatus(c)
+}
+
+// sendQueueStatus tells every waiting player its position in its queue and
+// the estimated wait.
+func (m *Matchmaking) sendQueueStatus(c *actor.Context) {
+	now := time.Now()
+	for key := range m.queueKeys() {
+		mode := key.Mode
+		queue := m.queue(key)
+		for i, group := range queue {
+			estimate := -1
+			if wait, ok := m.waitEstimate[mode]; ok {
+				estimate = int(max(0, wait-now.Sub(group.queuedAt)).Seconds())
+			}
+			for _, player := range group.players {
+				notifyPlayer(c, player.PID, &QueueStatusMsg{
+					State:         "queued",
+					Mode:          mode.String(),
+					Position:      i + 1,
+					QueueSize:     len(queue),
+					PartySize:     len(group.players),
+					Waited:        int(now.Sub(group.queuedAt).Seconds()),
+					EstimatedWait: &estimate,
+				})
+			}
+		}
+	}
+}
+
+// recordWait folds the wait of a matched player into the estimate of mode.
+func (m *Matchmaking) recordWait(mode GameModeType, wait time.Duration) {
+	estimate, ok := m.waitEstimate[mode]
+	if !ok {
+		m.waitEstimate[mode] = wait
+		return
+	}
+	m.waitEstimate[mode] = (estimate*7 + wait) / 8
+}
+
+func (m *Matchmaking) rating(pid *actor.PID) int {
+	if rating, ok := m.ratings[pid.String()]; ok {
+		return rating
+	}
+	return defaultRating
+}
+
+// updateRatings applies the Elo changes of a finished match.
+func (m *Matchmaking) updateRatings(result *MatchResult) {
+	for _, players := range result.Teams {
+		for _, pid := range players {
+			m.ratings[pid.String()] = m.rating(pid)
+		}
+	}
+
+	for team, change := range ratingChanges(result, m.ratings) {
+		for _, pid := range result.Teams[team] {
+			m.ratings[pid.String()] += change
+			fmt.Printf("Rating di %s: %d (%+d)\n", pid.String(), m.ratings[pid.String()], change)
+		}
+	}
+}
+
+// queueGroup is what the queue matches: a solo player or a whole party,
+// which always ends up on the same team.
+type queueGroup struct {
+	players  []*PlayerStatus
+	rating   int // average, for the rating window
+	total    int // sum, for team balancing
+	queuedAt time.Time
+}
+
+// queueKey identifies a queue: players are matched only with players of the
+// same mode and team size.
+type queueKey struct {
+	Mode     GameModeType
+	TeamSize int
+}
+
+func (p *PlayerStatus) queueKey() queueKey {
+	return queueKey{Mode: p.Mode, TeamSize: p.TeamSize}
+}
+
+// lineup is the lineup of a match of the queue key.
+func (m *Matchmaking) lineup(key queueKey) (teams, teamSize int) {
+	config := m.config
+	config.TeamSize = key.TeamSize
+	return key.Mode.Lineup(config)
+}
+
+// queueKeys returns the queues with at least one free player.
+func (m *Matchmaking) queueKeys() map[queueKey]bool {
+	keys := make(map[queueKey]bool)
+	for _, player := range m.players {
+		if player.Free {
+			keys[player.queueKey()] = true
+		}
+	}
+	return keys
+}
+
+// queue returns the free players waiting in the queue of key grouped by
+// party, longest waiting first.
+func (m *Matchmaking) queue(key queueKey) []*queueGroup {
+	var queue []*queueGroup
+	parties := make(map[int]*queueGroup)
+	for _, player := range m.players {
+		if !player.Free || player.queueKey() != key {
+			continue
+		}
+		group, ok := parties[player.PartyID]
+		if !ok || player.PartyID == 0 {
+			group = &queueGroup{queuedAt: player.QueuedAt}
+			queue = append(queue, group)
+			if player.PartyID != 0 {
+				parties[player.PartyID] = group
+			}
+		}
+		group.players = append(group.players, player)
+		group.total += player.Rating
+	}
+	for _, group := range queue {
+		group.rating = group.total / len(group.players)
+	}
+	sort.Slice(queue, func(i, j int) bool {
+		return queue[i].queuedAt.Before(queue[j].queuedAt)
+	})
+	return queue
+}
+
+// findMatches starts a match for every queue that can fill all the teams
+// with close enough ratings, as many times as it can.
+func (m *Matchmaking) findMatches(c *actor.Context) {
+	now := time.Now()
+	for key := range m.queueKeys() {
+		for {
+			groups := m.pickGroups(key, m.queue(key), now)
+			if groups == nil {
+				break
+			}
+			m.proposeMatch(c, key, groups)
+		}
+	}
+}
+
+// pickGroups gives the longest waiting group the closest ratings within its
+// rating window, until the match is full. If it can't be served yet, the
+// next one in line tries.
+func (m *Matchmaking) pickGroups(key queueKey, queue []*queueGroup, now time.Time) []*queueGroup {
+	teams, teamSize := m.lineup(key)
+	size := teams * teamSize
+
+	for _, anchor := range queue {
+		window := anchor.players[0].ratingWindow(now)
+
+		var candidates []*queueGroup
+		for _, group := range queue {
+			if group != anchor && abs(group.rating-anchor.rating) <= window {
+				candidates = append(candidates, group)
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return abs(candidates[i].rating-anchor.rating) < abs(candidates[j].rating-anchor.rating)
+		})
+
+		picked := []*queueGroup{anchor}
+		count := len(anchor.players)
+		for _, group := range candidates {
+			if count == size {
 				break
 			}
+			if count+len(group.players) <= size {
+				picked = append(picked, group)
+				count += len(group.players)
+			}
+		}
+		if count != size {
+			continue
+		}
+		if !key.Mode.IsFreeForAll() {
+			if _, ok := splitTeams(picked, teamSize); !ok {
+				continue
+			}
 		}
+		return picked
+	}
+	return nil
+}
 
-		if p1 != nil && p2 != nil {
+// splitTeams splits groups in two teams of teamSize players with the
+// closest total ratings, never breaking a party. It returns the groups of
+// the first team.
+func splitTeams(groups []*queueGroup, teamSize int) ([]*queueGroup, bool) {
+	total := 0
+	for _, group := range groups {
+		total += group.total
+	}
 
-			p1.Free = false
-			p2.Free = false
+	bestMask, bestDiff := -1, 0
+	// Al massimo 2*maxTeamSize gruppi: si provano tutte le divisioni
+	for mask := 0; mask < 1<<len(groups); mask++ {
+		players, rating := 0, 0
+		for i, group := range groups {
+			if mask&(1<<i) != 0 {
+				players += len(group.players)
+				rating += group.total
+			}
+		}
+		if players != teamSize {
+			continue
+		}
+		diff := abs(total - 2*rating)
+		if bestMask < 0 || diff < bestDiff {
+			bestMask, bestDiff = mask, diff
+		}
+	}
+	if bestMask < 0 {
+		return nil, false
+	}
 
-			fmt.Printf(" Match trovato: %s vs %s\n", p1.PID.String(), p2.PID.String())
-			c.SpawnChild(NewMatch(p1.PID, p2.PID), "match")
+	var first []*queueGroup
+	for i, group := range groups {
+		if bestMask&(1<<i) != 0 {
+			first = append(first, group)
 		}
 	}
+	return first, true
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func (m *Matchmaking) startMatch(c *actor.Context, key queueKey, groups []*queueGroup) {
+	mode := key.Mode
+	teams, teamSize := m.lineup(key)
+
+	// Squadre bilanciate sul rating totale, i party restano insieme
+	lawmen := make(map[*queueGroup]bool)
+	if !mode.IsFreeForAll() {
+		first, _ := splitTeams(groups, teamSize)
+		for _, group := range first {
+			lawmen[group] = true
+		}
+	}
+
+	roster := make(map[Team][]*actor.PID, teams)
+	ratings := make(map[*actor.PID]int)
+	now := time.Now()
+	solo := 0
+	for _, group := range groups {
+		for _, player := range group.players {
+			player.Proposal = 0
+			ratings[player.PID] = player.Rating
+			m.recordWait(mode, now.Sub(player.QueuedAt))
+			notifyPlayer(c, player.PID, &QueueStatusMsg{
+				State:  "matched",
+				Mode:   mode.String(),
+				Waited: int(now.Sub(player.QueuedAt).Seconds()),
+			})
+
+			team := TeamOutlaws
+			if lawmen[group] {
+				team = TeamLawmen
+			}
+			if mode.IsFreeForAll() {
+				team = SoloTeam(solo)
+				solo++
+			}
+			roster[team] = append(roster[team], player.PID)
+		}
+	}
+
+	config := m.config
+	config.Mode = mode
+	config.TeamSize = teamSize
+
+	if mode.IsFreeForAll() {
+		fmt.Printf(" Match trovato: %s, %d giocatori\n", mode, teams)
+	} else {
+		fmt.Printf(" Match trovato: %s %dv%d\n", mode, teamSize, teamSize)
+	}
+	c.SpawnChild(NewMatch(roster, ratings, config), "match")
 }
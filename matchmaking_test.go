@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestSplitTeams(t *testing.T) {
+	tests := []struct {
+		name     string
+		groups   [][]int // rating dei giocatori di ogni gruppo
+		teamSize int
+		wantOK   bool
+		wantDiff int // differenza tra i rating totali delle squadre
+	}{
+		{"solos balanced", [][]int{{1600}, {1400}, {1500}, {1500}}, 2, true, 0},
+		{"strongest apart", [][]int{{2000}, {1900}, {1000}, {1100}}, 2, true, 0},
+		{"party kept together", [][]int{{1500, 1500}, {1500}, {1500}}, 2, true, 0},
+		{"party against stronger solos", [][]int{{1200, 1200}, {1500}, {1400}}, 2, true, 500},
+		{"two parties", [][]int{{1500, 1600}, {1400, 1500}}, 2, true, 200},
+		{"party of three with a solo", [][]int{{1500, 1500, 1500}, {1500}, {1500}, {1500}, {1500}, {1500}}, 4, true, 0},
+		{"parties cannot fill a team", [][]int{{1500, 1500}, {1500, 1500}, {1500, 1500}}, 3, false, 0},
+		{"party bigger than a team", [][]int{{1500, 1500, 1500}, {1500}}, 2, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var groups []*queueGroup
+			total := 0
+			for _, ratings := range tt.groups {
+				group := &queueGroup{}
+				for _, rating := range ratings {
+					group.players = append(group.players, &PlayerStatus{Rating: rating})
+					group.total += rating
+				}
+				group.rating = group.total / len(ratings)
+				groups = append(groups, group)
+				total += group.total
+			}
+
+			first, ok := splitTeams(groups, tt.teamSize)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			players, rating := 0, 0
+			for _, group := range first {
+				players += len(group.players)
+				rating += group.total
+			}
+			if players != tt.teamSize {
+				t.Errorf("first team has %d players, want %d", players, tt.teamSize)
+			}
+			if diff := abs(total - 2*rating); diff != tt.wantDiff {
+				t.Errorf("rating difference = %d, want %d", diff, tt.wantDiff)
+			}
+		})
+	}
+}
@@ -0,0 +1,402 @@
+package main
+
+// ServerMessage is a message written to the client. On the wire it is an
+// object with the "action" name next to the message fields, see Codec.
+type ServerMessage interface {
+	ActionName() string
+}
+
+// Handshake e errori di protocollo
+
+// WelcomeMsg answers "hello": every later message uses Encoding. UDPToken
+// (hex) prefixes the datagrams sent to UDPPort, when UDP is available.
+type WelcomeMsg struct {
+	Version  int    `json:"version"`
+	Encoding string `json:"encoding"`
+	PlayerID string `json:"player_id"`
+	UDPToken string `json:"udp_token,omitempty"`
+	UDPPort  int    `json:"udp_port,omitempty"`
+}
+
+// UDPBoundMsg confirms on the websocket that the UDP endpoint is in use.
+type UDPBoundMsg struct{}
+
+type ErrorMsg struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Request string `json:"request,omitempty"` // action that caused the error
+}
+
+// Lobby: coda, ready check e party
+
+type QueueStatusMsg struct {
+	State         string `json:"state"` // queued, matched, left, lobby, rejected, cooldown
+	Mode          string `json:"mode,omitempty"`
+	Position      int    `json:"position,omitempty"`
+	QueueSize     int    `json:"queue_size,omitempty"`
+	PartySize     int    `json:"party_size,omitempty"`
+	Waited        int    `json:"waited,omitempty"`
+	EstimatedWait *int   `json:"estimated_wait,omitempty"` // -1 while unknown
+	Reason        string `json:"reason,omitempty"`
+	PlayerID      string `json:"player_id,omitempty"`
+	Remaining     int    `json:"remaining,omitempty"`
+}
+
+type MatchFoundMsg struct {
+	ProposalID   int     `json:"proposal_id"`
+	Mode         string  `json:"mode"`
+	Players      int     `json:"players"`
+	AcceptWithin float64 `json:"accept_within"`
+}
+
+type MatchAcceptStatusMsg struct {
+	ProposalID int `json:"proposal_id"`
+	Accepted   int `json:"accepted"`
+	Players    int `json:"players"`
+}
+
+type MatchCancelledMsg struct {
+	ProposalID int    `json:"proposal_id"`
+	Reason     string `json:"reason"`
+}
+
+type PartyUpdateMsg struct {
+	PartyID int      `json:"party_id"`
+	Leader  string   `json:"leader"`
+	Members []string `json:"members"`
+}
+
+type PartyInviteMsg struct {
+	PartyID int    `json:"party_id"`
+	From    string `json:"from"`
+}
+
+type PartyInviteSentMsg struct {
+	PlayerID string `json:"player_id"`
+}
+
+type PartyInviteDeclinedMsg struct {
+	PlayerID string `json:"player_id"`
+}
+
+type PartyLeftMsg struct {
+	PartyID int `json:"party_id"`
+}
+
+type PartyErrorMsg struct {
+	Reason string `json:"reason"`
+}
+
+// Ciclo di vita del match
+
+type MatchJoinedMsg struct {
+	Team     string              `json:"team"`
+	Mode     string              `json:"mode"`
+	PlayerID string              `json:"player_id"`
+	Rating   int                 `json:"rating"`
+	Roster   map[string][]string `json:"roster"`
+	Ratings  map[string]int      `json:"ratings"`
+}
+
+type MatchLeftMsg struct {
+	Reason string `json:"reason"`
+}
+
+// PlayerLeftMsg announces a player that disconnected mid-match.
+type PlayerLeftMsg struct {
+	PlayerID string `json:"player_id"`
+}
+
+type MatchEndMsg struct {
+	Winner       string         `json:"winner"`
+	FinalScore   map[string]int `json:"final_score"`
+	PlayerScores map[string]int `json:"player_scores"`
+}
+
+// ZoneInfo describes a map zone to the client.
+type ZoneInfo struct {
+	Zone   string  `json:"zone"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Z      float64 `json:"z"`
+	Radius float64 `json:"radius"`
+}
+
+// RoundStartMsg is shared by every mode, each one fills its own fields.
+type RoundStartMsg struct {
+	Round        int            `json:"round"`
+	Phase        string         `json:"phase"`
+	LawmenScore  int            `json:"lawmen_score"`
+	OutlawsScore int            `json:"outlaws_score"`
+	BuyTime      int            `json:"buy_time,omitempty"`
+	TimeLimit    int            `json:"time_limit,omitempty"`
+	KillLimit    int            `json:"kill_limit,omitempty"`
+	BestOf       int            `json:"best_of,omitempty"`
+	ScoreLimit   int            `json:"score_limit,omitempty"`
+	GoldTarget   int            `json:"gold_target,omitempty"`
+	Zones        []ZoneInfo     `json:"zones,omitempty"`
+	Money        map[string]int `json:"money,omitempty"` // teammates only
+}
+
+type RoundEndMsg struct {
+	Winner       string         `json:"winner"`
+	Reason       string         `json:"reason"`
+	LawmenScore  int            `json:"lawmen_score"`
+	OutlawsScore int            `json:"outlaws_score"`
+	Money        map[string]int `json:"money,omitempty"` // teammates only
+}
+
+type BuyTimeEndMsg struct {
+	Phase string `json:"phase"`
+}
+
+type ScoreUpdateMsg struct {
+	LawmenScore  int `json:"lawmen_score"`
+	OutlawsScore int `json:"outlaws_score"`
+}
+
+type TimerInfo struct {
+	ID          int    `json:"id"`
+	Timer       string `json:"timer"`
+	RemainingMs int64  `json:"remaining_ms"`
+}
+
+type TimerSyncMsg struct {
+	Round  int         `json:"round"`
+	Timers []TimerInfo `json:"timers"`
+}
+
+// Stato del mondo
+
+type SnapshotMsg struct {
+	Seq       int        `json:"seq"`
+	Self      string     `json:"self"`
+	Corrected bool       `json:"corrected"`
+	Full      bool       `json:"full"`
+	Base      int        `json:"base,omitempty"`
+	Entities  WorldState `json:"entities"`
+	Removed   []string   `json:"removed,omitempty"`
+
+	// Campi spariti da entità che esistono ancora: entity id -> chiavi
+	RemovedFields map[string][]string `json:"removed_fields,omitempty"`
+}
+
+type SpawnMsg struct {
+	X   float64 `json:"x"`
+	Y   float64 `json:"y"`
+	Z   float64 `json:"z"`
+	Yaw float64 `json:"yaw"`
+}
+
+type RespawnInMsg struct {
+	Seconds float64 `json:"seconds"`
+}
+
+// Combattimento
+
+type ActionRejectedMsg struct {
+	Rejected string `json:"rejected"`
+	Reason   string `json:"reason"`
+}
+
+type EnemyShootMsg struct {
+	Shooter    string     `json:"shooter"`
+	OriginX    float64    `json:"originX"`
+	OriginY    float64    `json:"originY"`
+	OriginZ    float64    `json:"originZ"`
+	DirX       float64    `json:"dirX"`
+	DirY       float64    `json:"dirY"`
+	DirZ       float64    `json:"dirZ"`
+	WeaponType WeaponType `json:"weapon_type"`
+}
+
+type HitConfirmedMsg struct {
+	Damage   int     `json:"damage"`
+	Headshot bool    `json:"headshot"`
+	Distance float64 `json:"distance"`
+}
+
+type HitMsg struct {
+	Damage   int  `json:"damage"`
+	Health   int  `json:"health"`
+	Headshot bool `json:"headshot"`
+}
+
+type PlayerDiedMsg struct{}
+
+type EnemyKilledMsg struct {
+	Money int `json:"money"`
+}
+
+// ExplosionHitMsg is "explosion_damage", or "burn_damage" for the fire
+// left by the blast.
+type ExplosionHitMsg struct {
+	Burn   bool `json:"-"`
+	Damage int  `json:"damage"`
+	Health int  `json:"health"`
+}
+
+type DynamiteThrownMsg struct {
+	ID     int     `json:"id"`
+	Owner  string  `json:"owner"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Z      float64 `json:"z"`
+	VX     float64 `json:"vx"`
+	VY     float64 `json:"vy"`
+	VZ     float64 `json:"vz"`
+	FuseMs int64   `json:"fuse_ms"`
+}
+
+type DynamiteExplodedMsg struct {
+	ID     int     `json:"id"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Z      float64 `json:"z"`
+	Radius float64 `json:"radius"`
+}
+
+// Economia e loadout
+
+type BuyFailedMsg struct {
+	Reason string `json:"reason"`
+}
+
+type BuySuccessMsg struct {
+	WeaponType WeaponType `json:"weapon_type"`
+	Money      int        `json:"money"`
+}
+
+type LoadoutFailedMsg struct {
+	Reason string `json:"reason"`
+}
+
+type LoadoutSelectedMsg struct {
+	WeaponType WeaponType `json:"weapon_type"`
+}
+
+// Search and Destroy
+
+type BombPlantedMsg struct {
+	PlantedBy string `json:"planted_by"`
+	Site      string `json:"site"`
+}
+
+type BombDefusedMsg struct {
+	DefusedBy string `json:"defused_by"`
+}
+
+type BombDroppedMsg struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+type BombPickedUpMsg struct {
+	Carrier string `json:"carrier"`
+}
+
+// BombChannelMsg is "plant_progress" or "defuse_progress".
+type BombChannelMsg struct {
+	Defuse   bool    `json:"-"`
+	State    string  `json:"state"` // started, progress, cancelled, rejected
+	Player   string  `json:"player,omitempty"`
+	Site     string  `json:"site,omitempty"`
+	Progress float64 `json:"progress"`
+	Duration float64 `json:"duration,omitempty"`
+	Reason   string  `json:"reason,omitempty"`
+}
+
+// Duel, Bounty Hunter, Gold Rush
+
+type DuelCountdownMsg struct {
+	Step string `json:"step"`
+}
+
+type BountyClaimedMsg struct {
+	Hunter  string `json:"hunter"`
+	Outlaw  string `json:"outlaw"`
+	Reward  int    `json:"reward"`
+	Balance int    `json:"balance"`
+}
+
+type BountyLeaderboardMsg struct {
+	Leaderboard []bountyEntry `json:"leaderboard"`
+}
+
+type ZoneUpdateMsg struct {
+	Zone      string  `json:"zone"`
+	Owner     string  `json:"owner"`
+	Capturing string  `json:"capturing"`
+	Progress  float64 `json:"progress"`
+	Contested bool    `json:"contested"`
+}
+
+func (*WelcomeMsg) ActionName() string             { return "welcome" }
+func (*UDPBoundMsg) ActionName() string            { return "udp_bound" }
+func (*ErrorMsg) ActionName() string               { return "error" }
+func (*QueueStatusMsg) ActionName() string         { return "queue_status" }
+func (*MatchFoundMsg) ActionName() string          { return "match_found" }
+func (*MatchAcceptStatusMsg) ActionName() string   { return "match_accept_status" }
+func (*MatchCancelledMsg) ActionName() string      { return "match_cancelled" }
+func (*PartyUpdateMsg) ActionName() string         { return "party_update" }
+func (*PartyInviteMsg) ActionName() string         { return "party_invite" }
+func (*PartyInviteSentMsg) ActionName() string     { return "party_invite_sent" }
+func (*PartyInviteDeclinedMsg) ActionName() string { return "party_invite_declined" }
+func (*PartyLeftMsg) ActionName() string           { return "party_left" }
+func (*PartyErrorMsg) ActionName() string          { return "party_error" }
+func (*MatchJoinedMsg) ActionName() string         { return "match_joined" }
+func (*MatchLeftMsg) ActionName() string           { return "match_left" }
+func (*PlayerLeftMsg) ActionName() string          { return "player_left" }
+func (*MatchEndMsg) ActionName() string            { return "match_end" }
+func (*RoundStartMsg) ActionName() string          { return "round_start" }
+func (*RoundEndMsg) ActionName() string            { return "round_end" }
+func (*BuyTimeEndMsg) ActionName() string          { return "buy_time_end" }
+func (*ScoreUpdateMsg) ActionName() string         { return "score_update" }
+func (*TimerSyncMsg) ActionName() string           { return "timer_sync" }
+func (*SnapshotMsg) ActionName() string            { return "snapshot" }
+func (*SpawnMsg) ActionName() string               { return "spawn" }
+func (*RespawnInMsg) ActionName() string           { return "respawn_in" }
+func (*ActionRejectedMsg) ActionName() string      { return "action_rejected" }
+func (*EnemyShootMsg) ActionName() string          { return "enemy_shoot" }
+func (*HitConfirmedMsg) ActionName() string        { return "hit_confirmed" }
+func (*HitMsg) ActionName() string                 { return "hit" }
+func (*PlayerDiedMsg) ActionName() string          { return "player_died" }
+func (*EnemyKilledMsg) ActionName() string         { return "enemy_killed" }
+func (*DynamiteThrownMsg) ActionName() string      { return "dynamite_thrown" }
+func (*DynamiteExplodedMsg) ActionName() string    { return "dynamite_exploded" }
+func (*BuyFailedMsg) ActionName() string           { return "buy_failed" }
+func (*BuySuccessMsg) ActionName() string          { return "buy_success" }
+func (*LoadoutFailedMsg) ActionName() string       { return "loadout_failed" }
+func (*LoadoutSelectedMsg) ActionName() string     { return "loadout_selected" }
+func (*BombPlantedMsg) ActionName() string         { return "bomb_planted" }
+func (*BombDefusedMsg) ActionName() string         { return "bomb_defused" }
+func (*BombDroppedMsg) ActionName() string         { return "bomb_dropped" }
+func (*BombPickedUpMsg) ActionName() string        { return "bomb_picked_up" }
+func (*DuelCountdownMsg) ActionName() string       { return "duel_countdown" }
+func (*BountyClaimedMsg) ActionName() string       { return "bounty_claimed" }
+func (*BountyLeaderboardMsg) ActionName() string   { return "bounty_leaderboard" }
+func (*ZoneUpdateMsg) ActionName() string          { return "zone_update" }
+
+// unreliableMessage is a message that may get lost: it goes over UDP when
+// the client uses it.
+type unreliableMessage interface {
+	ServerMessage
+	unreliable()
+}
+
+func (*SnapshotMsg) unreliable() {}
+
+func (m *ExplosionHitMsg) ActionName() string {
+	if m.Burn {
+		return "burn_damage"
+	}
+	return "explosion_damage"
+}
+
+func (m *BombChannelMsg) ActionName() string {
+	if m.Defuse {
+		return "defuse_progress"
+	}
+	return "plant_progress"
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+type Stance int
+
+const (
+	StanceStanding Stance = iota
+	StanceCrouching
+)
+
+// Limiti di movimento validati dal server
+const (
+	maxRunSpeed      = 6.5  // m/s in piedi
+	maxCrouchSpeed   = 3.0  // m/s accovacciato
+	maxVerticalSpeed = 20.0 // cadute
+	maxJumpSpeed     = 5.0  // spinta del salto: in salita non si va più veloci
+	maxAcceleration  = 40.0 // m/s^2
+
+	// Tolleranza per jitter di rete e clock del client
+	moveSpeedTolerance = 1.25
+	moveSlack          = 0.05 // secondi di movimento concessi oltre il budget
+
+	// Tempo di movimento accumulabile: copre i pacchetti che arrivano in
+	// blocco dopo un ritardo, senza permettere di "caricare" un teletrasporto
+	moveBurstWindow = 0.25 // secondi
+
+	maxPitch = 89.0
+)
+
+// PlayerState is the authoritative kinematic state of a player inside a Match.
+type PlayerState struct {
+	Position   Position
+	Velocity   Position
+	Yaw        float64
+	Pitch      float64
+	Stance     Stance
+	Seq        int
+	LastUpdate time.Time
+
+	// Secondi di movimento maturati e non ancora usati
+	moveBudget float64
+}
+
+// MoveInput is a "move" message as sent by the client.
+type MoveInput struct {
+	Position Position
+	Velocity Position
+	Yaw      float64
+	Pitch    float64
+	Stance   Stance
+	Seq      int
+}
+
+func (s Stance) String() string {
+	if s == StanceCrouching {
+		return "crouch"
+	}
+	return "stand"
+}
+
+func (s Stance) MaxSpeed() float64 {
+	if s == StanceCrouching {
+		return maxCrouchSpeed
+	}
+	return maxRunSpeed
+}
+
+// ApplyMove validates in against the current state and applies it.
+// Movement is checked against a time budget rather than per input: the
+// budget grows with the time elapsed between inputs, up to moveBurstWindow,
+// and every input spends the least time its displacement and velocity
+// change need at the speed limits. Inputs bunched together by the network
+// spend time saved by the ones before; only when the budget is overdrawn by
+// more than moveSlack is the input clamped along its direction and
+// corrected reports that the client must be snapped back.
+// Going up is limited to the jump speed, falling to maxVerticalSpeed, and
+// the position is kept inside bounds, the playable volume of the map (a
+// zero Box means no bounds).
+// Stale or duplicated inputs (Seq not increasing) are rejected.
+func (s *PlayerState) ApplyMove(in MoveInput, bounds Box, now time.Time) (accepted bool, corrected bool) {
+	if s.LastUpdate.IsZero() {
+		// Primo aggiornamento: nessuno stato precedente da confrontare
+		s.Position = clampToBounds(in.Position, bounds)
+		s.Velocity = clampVelocity(in.Velocity, in.Stance.MaxSpeed())
+		s.setView(in)
+		s.LastUpdate = now
+		s.moveBudget = 0
+		return true, s.Position != in.Position
+	}
+
+	if in.Seq <= s.Seq {
+		return false, false
+	}
+
+	elapsed := now.Sub(s.LastUpdate).Seconds()
+	s.moveBudget = math.Min(s.moveBudget+math.Max(elapsed, 0), moveBurstWindow)
+	maxSpeed := math.Max(in.Stance.MaxSpeed(), s.Stance.MaxSpeed())
+
+	// Velocità dichiarata
+	velocity := clampVelocity(in.Velocity, maxSpeed)
+	if velocity != in.Velocity {
+		corrected = true
+	}
+
+	horizontalRate := maxSpeed * moveSpeedTolerance
+	verticalRate := maxVerticalSpeed * moveSpeedTolerance
+	if in.Position.Y > s.Position.Y {
+		verticalRate = maxJumpSpeed * moveSpeedTolerance
+	}
+	accelerationRate := maxAcceleration * moveSpeedTolerance
+
+	dv := velocity.Sub(s.Velocity)
+	delta := in.Position.Sub(s.Position)
+	horizontal := Position{X: delta.X, Z: delta.Z}
+
+	// Tempo minimo per spostamento e cambio di velocità
+	needed := math.Max(horizontal.Length()/horizontalRate,
+		math.Max(math.Abs(delta.Y)/verticalRate, dv.Length()/accelerationRate))
+
+	allowed := s.moveBudget + moveSlack
+	if needed > allowed {
+		if horizontal.Length() > horizontalRate*allowed {
+			horizontal = horizontal.Normalize().Scale(horizontalRate * allowed)
+		}
+		if math.Abs(delta.Y) > verticalRate*allowed {
+			delta.Y = math.Copysign(verticalRate*allowed, delta.Y)
+		}
+		if dv.Length() > accelerationRate*allowed {
+			velocity = s.Velocity.Add(dv.Normalize().Scale(accelerationRate * allowed))
+		}
+		needed = allowed
+		corrected = true
+	}
+	s.moveBudget -= needed
+
+	position := s.Position.Add(Position{X: horizontal.X, Y: delta.Y, Z: horizontal.Z})
+	s.Position = clampToBounds(position, bounds)
+	if s.Position != position {
+		corrected = true
+	}
+	s.Velocity = velocity
+	s.setView(in)
+	s.LastUpdate = now
+	return true, corrected
+}
+
+func (s *PlayerState) setView(in MoveInput) {
+	s.Yaw = math.Mod(in.Yaw, 360)
+	if s.Yaw < 0 {
+		s.Yaw += 360
+	}
+	s.Pitch = math.Max(-maxPitch, math.Min(maxPitch, in.Pitch))
+	s.Stance = in.Stance
+	s.Seq = in.Seq
+}
+
+func clampVelocity(v Position, maxHorizontal float64) Position {
+	horizontal := Position{X: v.X, Z: v.Z}
+	if horizontal.Length() > maxHorizontal {
+		horizontal = horizontal.Normalize().Scale(maxHorizontal)
+	}
+	return Position{
+		X: horizontal.X,
+		Y: math.Max(-maxVerticalSpeed, math.Min(maxJumpSpeed, v.Y)),
+		Z: horizontal.Z,
+	}
+}
+
+func clampToBounds(p Position, bounds Box) Position {
+	if bounds == (Box{}) {
+		return p
+	}
+	return Position{
+		X: math.Max(bounds.Min.X, math.Min(bounds.Max.X, p.X)),
+		Y: math.Max(bounds.Min.Y, math.Min(bounds.Max.Y, p.Y)),
+		Z: math.Max(bounds.Min.Z, math.Min(bounds.Max.Z, p.Z)),
+	}
+}
+
+// ToMap renders the sanitized state for clients.
+func (s *PlayerState) ToMap() map[string]interface{} {
+	return map[string]interface{}{
+		"x":      s.Position.X,
+		"y":      s.Position.Y,
+		"z":      s.Position.Z,
+		"vx":     s.Velocity.X,
+		"vy":     s.Velocity.Y,
+		"vz":     s.Velocity.Z,
+		"yaw":    s.Yaw,
+		"pitch":  s.Pitch,
+		"stance": s.Stance.String(),
+		"seq":    s.Seq,
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// runMoves feeds count inputs of a client sending at 60 Hz and moving
+// along X at speed. After the first one, inputs are delivered by the
+// network in bursts of burst inputs. It returns how many were corrected.
+func runMoves(t *testing.T, state *PlayerState, speed float64, count, burst int) int {
+	const clientRate = 60
+	start := time.Now()
+	corrected := 0
+	for i := 0; i < count; i++ {
+		// L'input i parte a i/60 s, arriva insieme all'ultimo del suo blocco
+		last := 0
+		if i > 0 {
+			last = ((i-1)/burst + 1) * burst
+		}
+		receivedAt := start.Add(time.Duration(last) * time.Second / clientRate)
+		in := MoveInput{
+			Position: Position{X: speed * float64(i) / clientRate},
+			Velocity: Position{X: speed},
+			Seq:      i + 1,
+		}
+		accepted, c := state.ApplyMove(in, Box{}, receivedAt)
+		if !accepted {
+			t.Fatalf("move %d rejected", i)
+		}
+		if c {
+			corrected++
+		}
+	}
+	return corrected
+}
+
+func TestApplyMoveToleratesNetworkJitter(t *testing.T) {
+	tests := []struct {
+		name  string
+		speed float64
+		burst int
+	}{
+		{"steady walk", 4.0, 1},
+		{"steady run", maxRunSpeed, 1},
+		{"pairs at 6.0", 6.0, 2},
+		{"pairs at max speed", maxRunSpeed, 2},
+		{"bursts of 4 at max speed", maxRunSpeed, 4},
+		{"bursts of 8 at max speed", maxRunSpeed, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &PlayerState{}
+			if corrected := runMoves(t, state, tt.speed, 120, tt.burst); corrected != 0 {
+				t.Errorf("%d/120 legit moves corrected", corrected)
+			}
+		})
+	}
+}
+
+func TestApplyMoveCorrectsSpeedHack(t *testing.T) {
+	state := &PlayerState{}
+	speed := maxRunSpeed * 2
+	if corrected := runMoves(t, state, speed, 120, 1); corrected == 0 {
+		t.Fatal("moving at twice the max speed was never corrected")
+	}
+
+	// 2 secondi di gioco: non oltre il limite più la tolleranza
+	limit := maxRunSpeed*moveSpeedTolerance*(119.0/60+moveSlack) + 1e-9
+	if state.Position.X > limit {
+		t.Errorf("travelled %.2f m, limit %.2f m", state.Position.X, limit)
+	}
+}
+
+func TestApplyMoveClampsTeleport(t *testing.T) {
+	state := &PlayerState{}
+	now := time.Now()
+	state.ApplyMove(MoveInput{Seq: 1}, Box{}, now)
+
+	// Fermo per un secondo: il budget non va oltre moveBurstWindow
+	now = now.Add(time.Second)
+	accepted, corrected := state.ApplyMove(MoveInput{Position: Position{X: 50}, Seq: 2}, Box{}, now)
+	if !accepted || !corrected {
+		t.Fatalf("teleport accepted=%v corrected=%v, want accepted and corrected", accepted, corrected)
+	}
+	limit := maxRunSpeed * moveSpeedTolerance * (moveBurstWindow + moveSlack)
+	if state.Position.X <= 0 || state.Position.X > limit+1e-9 {
+		t.Errorf("position %.2f, want in (0, %.2f]", state.Position.X, limit)
+	}
+}
+
+func TestApplyMoveClampsDeclaredVelocity(t *testing.T) {
+	state := &PlayerState{}
+	now := time.Now()
+	state.ApplyMove(MoveInput{Seq: 1}, Box{}, now)
+
+	in := MoveInput{Velocity: Position{X: 3}, Stance: StanceCrouching, Seq: 2}
+	if _, corrected := state.ApplyMove(in, Box{}, now.Add(time.Second)); corrected {
+		t.Error("crouch speed corrected")
+	}
+	in = MoveInput{Velocity: Position{X: 10}, Stance: StanceCrouching, Seq: 3}
+	if _, corrected := state.ApplyMove(in, Box{}, now.Add(2*time.Second)); !corrected {
+		t.Error("10 m/s crouching not corrected")
+	}
+	if state.Velocity.X > maxCrouchSpeed {
+		t.Errorf("velocity %.2f over the crouch limit", state.Velocity.X)
+	}
+}
+
+func TestApplyMoveRejectsStaleSeq(t *testing.T) {
+	state := &PlayerState{}
+	now := time.Now()
+	state.ApplyMove(MoveInput{Seq: 5}, Box{}, now)
+	if accepted, _ := state.ApplyMove(MoveInput{Position: Position{X: 0.1}, Seq: 5}, Box{}, now.Add(time.Second/60)); accepted {
+		t.Error("duplicated seq accepted")
+	}
+	if accepted, _ := state.ApplyMove(MoveInput{Seq: 4}, Box{}, now.Add(time.Second/30)); accepted {
+		t.Error("stale seq accepted")
+	}
+}
+
+func TestApplyMoveLimitsVerticalMovement(t *testing.T) {
+	bounds := Maps[defaultMapName].Bounds
+	// Salita massima con il budget pieno
+	jumpLimit := (moveBurstWindow + moveSlack) * maxJumpSpeed * moveSpeedTolerance
+	tests := []struct {
+		name          string
+		y             float64 // quota dichiarata dopo un secondo
+		wantCorrected bool
+		wantY         float64
+	}{
+		{"jump onto a crate", 1.2, false, 1.2},
+		{"climb a roof in a second", 7, true, jumpLimit},
+		{"fly above the ceiling", 50, true, jumpLimit},
+		{"dig under the floor", -3, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &PlayerState{}
+			now := time.Now()
+			state.ApplyMove(MoveInput{Seq: 1}, bounds, now)
+
+			in := MoveInput{Position: Position{Y: tt.y}, Seq: 2}
+			_, corrected := state.ApplyMove(in, bounds, now.Add(time.Second))
+			if corrected != tt.wantCorrected {
+				t.Errorf("corrected = %v, want %v", corrected, tt.wantCorrected)
+			}
+			if math.Abs(state.Position.Y-tt.wantY) > 1e-9 {
+				t.Errorf("y = %.3f, want %.3f", state.Position.Y, tt.wantY)
+			}
+		})
+	}
+}
+
+func TestApplyMoveKeepsPlayersUnderTheCeiling(t *testing.T) {
+	bounds := Maps[defaultMapName].Bounds
+	state := &PlayerState{}
+	now := time.Now()
+	state.ApplyMove(MoveInput{Position: Position{Y: 8}, Seq: 1}, bounds, now)
+
+	// Salti continui dal tetto della banca: mai oltre il soffitto
+	for seq := 2; seq < 60; seq++ {
+		now = now.Add(time.Second / 10)
+		in := MoveInput{Position: Position{Y: state.Position.Y + 0.5}, Seq: seq}
+		state.ApplyMove(in, bounds, now)
+	}
+	if state.Position.Y > bounds.Max.Y {
+		t.Errorf("y = %.2f above the ceiling %.2f", state.Position.Y, bounds.Max.Y)
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// Party is a premade group: it queues together and always plays on the
+// same team.
+type Party struct {
+	ID      int
+	Leader  *actor.PID
+	Members []*actor.PID
+}
+
+// Parties owns every party and pending invite. It sits next to Matchmaking
+// under the Server: queue requests go through it so that a party leader
+// enqueues the whole group.
+type Parties struct {
+	matchmaking *actor.PID
+
+	sessions map[string]*actor.PID // online players by player id
+	parties  map[int]*Party
+	partyOf  map[string]int // player id -> party
+	invites  map[string]int // invited player id -> party
+	nextID   int
+}
+
+func NewParties(matchmaking *actor.PID) actor.Producer {
+	return func() actor.Receiver {
+		return &Parties{
+			matchmaking: matchmaking,
+			sessions:    make(map[string]*actor.PID),
+			parties:     make(map[int]*Party),
+			partyOf:     make(map[string]int),
+			invites:     make(map[string]int),
+		}
+	}
+}
+
+// Comandi di party inoltrati dalla PlayerSession
+type PartyCommand struct {
+	From     *actor.PID
+	Action   string
+	PlayerID string // invited player, for "party_invite"
+}
+
+// PartyChanged tells Matchmaking the new members of a party after someone
+// joined or left it. Left are the players that are no longer in it.
+type PartyChanged struct {
+	PartyID int
+	Members []*actor.PID
+	Left    []*actor.PID
+}
+
+// Sessioni connesse, per trovare i giocatori da invitare. Identity resta
+// la stessa se il giocatore si riconnette.
+type SessionOnline struct {
+	PID      *actor.PID
+	Identity string
+}
+
+type SessionOffline struct {
+	PID *actor.PID
+}
+
+func (p *Parties) Receive(c *actor.Context) {
+	switch msg := c.Message().(type) {
+	case *SessionOnline:
+		p.sessions[playerID(msg.PID)] = msg.PID
+
+	case *SessionOffline:
+		delete(p.sessions, playerID(msg.PID))
+		delete(p.invites, playerID(msg.PID))
+		p.leave(c, msg.PID)
+
+	case *QueueRequest:
+		p.queue(c, msg)
+
+	case *PartyCommand:
+		switch msg.Action {
+		case "party_create":
+			p.create(c, msg.From)
+		case "party_invite":
+			p.invite(c, msg.From, msg.PlayerID)
+		case "party_accept":
+			p.accept(c, msg.From)
+		case "party_decline":
+			p.decline(c, msg.From)
+		case "party_leave":
+			p.leave(c, msg.From)
+		}
+	}
+}
+
+func (p *Parties) create(c *actor.Context, pid *actor.PID) *Party {
+	if _, ok := p.partyOf[playerID(pid)]; ok {
+		p.fail(c, pid, "already_in_party")
+		return nil
+	}
+
+	p.nextID++
+	party := &Party{ID: p.nextID, Leader: pid, Members: []*actor.PID{pid}}
+	p.parties[party.ID] = party
+	p.partyOf[playerID(pid)] = party.ID
+
+	fmt.Printf("Party %d creato da %s\n", party.ID, pid.String())
+
+	p.broadcastUpdate(c, party)
+	return party
+}
+
+// invite sends target an invite to the party of pid, creating the party if
+// pid is not in one yet. Only the leader invites.
+func (p *Parties) invite(c *actor.Context, pid *actor.PID, target string) {
+	targetPID, online := p.sessions[target]
+	if !online {
+		p.fail(c, pid, "player_not_found")
+		return
+	}
+	if _, ok := p.partyOf[target]; ok {
+		p.fail(c, pid, "player_already_in_party")
+		return
+	}
+
+	party := p.partyOfPlayer(pid)
+	if party == nil {
+		if party = p.create(c, pid); party == nil {
+			return
+		}
+	}
+	if party.Leader != pid {
+		p.fail(c, pid, "not_party_leader")
+		return
+	}
+	if len(party.Members) >= maxTeamSize {
+		p.fail(c, pid, "party_full")
+		return
+	}
+
+	p.invites[target] = party.ID
+	notifyPlayer(c, targetPID, &PartyInviteMsg{PartyID: party.ID, From: playerID(pid)})
+	notifyPlayer(c, pid, &PartyInviteSentMsg{PlayerID: target})
+}
+
+func (p *Parties) accept(c *actor.Context, pid *actor.PID) {
+	id, invited := p.invites[playerID(pid)]
+	delete(p.invites, playerID(pid))
+
+	party, ok := p.parties[id]
+	if !invited || !ok {
+		p.fail(c, pid, "no_pending_invite")
+		return
+	}
+	if _, ok := p.partyOf[playerID(pid)]; ok {
+		p.fail(c, pid, "already_in_party")
+		return
+	}
+	if len(party.Members) >= maxTeamSize {
+		p.fail(c, pid, "party_full")
+		return
+	}
+
+	party.Members = append(party.Members, pid)
+	p.partyOf[playerID(pid)] = party.ID
+
+	// Il gruppo è cambiato: chi era in coda deve rientrarci col party nuovo
+	p.notifyChanged(c, party, nil)
+
+	fmt.Printf("%s entrato nel party %d\n", pid.String(), party.ID)
+
+	p.broadcastUpdate(c, party)
+}
+
+func (p *Parties) decline(c *actor.Context, pid *actor.PID) {
+	id, invited := p.invites[playerID(pid)]
+	delete(p.invites, playerID(pid))
+
+	party, ok := p.parties[id]
+	if !invited || !ok {
+		return
+	}
+	notifyPlayer(c, party.Leader, &PartyInviteDeclinedMsg{PlayerID: playerID(pid)})
+}
+
+// leave removes pid from its party. The party is disbanded when empty,
+// otherwise the oldest member becomes the leader.
+func (p *Parties) leave(c *actor.Context, pid *actor.PID) {
+	party := p.partyOfPlayer(pid)
+	if party == nil {
+		return
+	}
+
+	delete(p.partyOf, playerID(pid))
+	for i, member := range party.Members {
+		if member == pid {
+			party.Members = append(party.Members[:i], party.Members[i+1:]...)
+			break
+		}
+	}
+
+	// Il party esce dalla coda con lui
+	p.notifyChanged(c, party, pid)
+
+	notifyPlayer(c, pid, &PartyLeftMsg{PartyID: party.ID})
+
+	if len(party.Members) == 0 {
+		delete(p.parties, party.ID)
+		fmt.Printf("Party %d sciolto\n", party.ID)
+		return
+	}
+	if party.Leader == pid {
+		party.Leader = party.Members[0]
+	}
+	p.broadcastUpdate(c, party)
+}
+
+// queue forwards a queue request to Matchmaking: alone if the player has
+// no party, with the whole party if it is the leader.
+func (p *Parties) queue(c *actor.Context, req *QueueRequest) {
+	party := p.partyOfPlayer(req.PID)
+	if party == nil {
+		c.Send(p.matchmaking, req)
+		return
+	}
+	if party.Leader != req.PID {
+		p.fail(c, req.PID, "only_leader_can_queue")
+		return
+	}
+
+	c.Send(p.matchmaking, &QueueRequest{
+		PID:      req.PID,
+		Mode:     req.Mode,
+		TeamSize: req.TeamSize,
+		Requeue:  req.Requeue,
+		Party:    append([]*actor.PID(nil), party.Members...),
+		PartyID:  party.ID,
+	})
+}
+
+// notifyChanged sends the members of party, and the player that left it if
+// any, to Matchmaking.
+func (p *Parties) notifyChanged(c *actor.Context, party *Party, left *actor.PID) {
+	msg := &PartyChanged{
+		PartyID: party.ID,
+		Members: append([]*actor.PID(nil), party.Members...),
+	}
+	if left != nil {
+		msg.Left = []*actor.PID{left}
+	}
+	c.Send(p.matchmaking, msg)
+}
+
+func (p *Parties) partyOfPlayer(pid *actor.PID) *Party {
+	id, ok := p.partyOf[playerID(pid)]
+	if !ok {
+		return nil
+	}
+	return p.parties[id]
+}
+
+func (p *Parties) broadcastUpdate(c *actor.Context, party *Party) {
+	update := &PartyUpdateMsg{
+		PartyID: party.ID,
+		Leader:  playerID(party.Leader),
+		Members: playerIDs(party.Members),
+	}
+	for _, member := range party.Members {
+		notifyPlayer(c, member, update)
+	}
+}
+
+func (p *Parties) fail(c *actor.Context, pid *actor.PID, reason string) {
+	notifyPlayer(c, pid, &PartyErrorMsg{Reason: reason})
+}
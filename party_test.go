@@ -0,0 +1,205 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// probe stands in for a PlayerSession: it records what the lobby actors
+// send to the player.
+type probe struct {
+	pid  *actor.PID
+	msgs chan ServerMessage
+	seen []ServerMessage
+}
+
+func newProbe(e *actor.Engine, name string) *probe {
+	p := &probe{msgs: make(chan ServerMessage, 1024)}
+	p.pid = e.SpawnFunc(func(c *actor.Context) {
+		if msg, ok := c.Message().(ServerMessage); ok {
+			p.msgs <- msg
+		}
+	}, name)
+	return p
+}
+
+// expect waits for the first message want accepts.
+func (p *probe) expect(t *testing.T, what string, want func(ServerMessage) bool) ServerMessage {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case msg := <-p.msgs:
+			p.seen = append(p.seen, msg)
+			if want(msg) {
+				return msg
+			}
+		case <-timeout:
+			t.Fatalf("%s: no %s", p.pid, what)
+			return nil
+		}
+	}
+}
+
+// got reports whether any message received so far matches want.
+func (p *probe) got(want func(ServerMessage) bool) bool {
+	for {
+		select {
+		case msg := <-p.msgs:
+			p.seen = append(p.seen, msg)
+			continue
+		default:
+		}
+		break
+	}
+	for _, msg := range p.seen {
+		if want(msg) {
+			return true
+		}
+	}
+	return false
+}
+
+func isMatchFound(msg ServerMessage) bool {
+	_, ok := msg.(*MatchFoundMsg)
+	return ok
+}
+
+func isMatchCancelled(msg ServerMessage) bool {
+	_, ok := msg.(*MatchCancelledMsg)
+	return ok
+}
+
+func queueState(state string) func(ServerMessage) bool {
+	return func(msg ServerMessage) bool {
+		status, ok := msg.(*QueueStatusMsg)
+		return ok && status.State == state
+	}
+}
+
+func newTestEngine(t *testing.T) *actor.Engine {
+	e, err := actor.NewEngine(actor.NewEngineConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestAcceptInviteDuringReadyCheck(t *testing.T) {
+	e := newTestEngine(t)
+	mm := e.Spawn(NewMatchmaking(DefaultMatchConfig()), "matchmaking")
+	parties := e.Spawn(NewParties(mm), "parties")
+	defer e.Poison(parties)
+	defer e.Poison(mm)
+
+	a, b, leader := newProbe(e, "a"), newProbe(e, "b"), newProbe(e, "leader")
+	for _, p := range []*probe{a, b, leader} {
+		e.Send(parties, &SessionOnline{PID: p.pid})
+	}
+
+	// a e b trovano un duello, poi a entra nel party di leader
+	e.Send(mm, &QueueRequest{PID: a.pid, Mode: Duel})
+	e.Send(mm, &QueueRequest{PID: b.pid, Mode: Duel})
+	found := a.expect(t, "match_found", isMatchFound).(*MatchFoundMsg)
+	b.expect(t, "match_found", isMatchFound)
+
+	e.Send(parties, &PartyCommand{From: leader.pid, Action: "party_invite", PlayerID: playerID(a.pid)})
+	a.expect(t, "party_invite", func(msg ServerMessage) bool {
+		_, ok := msg.(*PartyInviteMsg)
+		return ok
+	})
+	e.Send(parties, &PartyCommand{From: a.pid, Action: "party_accept"})
+	leader.expect(t, "party_update", func(msg ServerMessage) bool {
+		update, ok := msg.(*PartyUpdateMsg)
+		return ok && len(update.Members) == 2
+	})
+
+	// Il ready check non è stato rifiutato: il match parte
+	e.Send(mm, &MatchResponse{PID: a.pid, ProposalID: found.ProposalID, Accept: true})
+	e.Send(mm, &MatchResponse{PID: b.pid, ProposalID: found.ProposalID, Accept: true})
+	a.expect(t, "matched", queueState("matched"))
+	b.expect(t, "matched", queueState("matched"))
+
+	for _, p := range []*probe{a, b} {
+		if p.got(isMatchCancelled) || p.got(queueState("cooldown")) {
+			t.Errorf("%s: accepting a party invite cancelled the match", p.pid)
+		}
+	}
+}
+
+func TestAcceptInviteWhileQueued(t *testing.T) {
+	e := newTestEngine(t)
+	mm := e.Spawn(NewMatchmaking(DefaultMatchConfig()), "matchmaking")
+	parties := e.Spawn(NewParties(mm), "parties")
+	defer e.Poison(parties)
+	defer e.Poison(mm)
+
+	member, leader := newProbe(e, "member"), newProbe(e, "leader")
+	for _, p := range []*probe{member, leader} {
+		e.Send(parties, &SessionOnline{PID: p.pid})
+	}
+
+	e.Send(mm, &QueueRequest{PID: member.pid, Mode: TeamDeathmatch})
+	member.expect(t, "queued", queueState("queued"))
+
+	e.Send(parties, &PartyCommand{From: leader.pid, Action: "party_invite", PlayerID: playerID(member.pid)})
+	e.Send(parties, &PartyCommand{From: member.pid, Action: "party_accept"})
+
+	// Fuori dalla coda da solo, senza cooldown: ci rientra col leader
+	left := member.expect(t, "left", queueState("left")).(*QueueStatusMsg)
+	if left.Reason != "party_changed" {
+		t.Errorf("left the queue for %q, want party_changed", left.Reason)
+	}
+	e.Send(parties, &QueueRequest{PID: leader.pid, Mode: TeamDeathmatch})
+	status := member.expect(t, "queued", queueState("queued")).(*QueueStatusMsg)
+	if status.PartySize != 2 {
+		t.Errorf("queued with party size %d, want 2", status.PartySize)
+	}
+}
+
+func TestJoinPartyDuringMatch(t *testing.T) {
+	e := newTestEngine(t)
+	mm := e.Spawn(NewMatchmaking(DefaultMatchConfig()), "matchmaking")
+	parties := e.Spawn(NewParties(mm), "parties")
+	defer e.Poison(parties)
+	defer e.Poison(mm)
+
+	a, b, leader := newProbe(e, "a"), newProbe(e, "b"), newProbe(e, "leader")
+	for _, p := range []*probe{a, b, leader} {
+		e.Send(parties, &SessionOnline{PID: p.pid})
+	}
+
+	e.Send(mm, &QueueRequest{PID: a.pid, Mode: Duel, Requeue: true})
+	e.Send(mm, &QueueRequest{PID: b.pid, Mode: Duel, Requeue: true})
+	found := a.expect(t, "match_found", isMatchFound).(*MatchFoundMsg)
+	e.Send(mm, &MatchResponse{PID: a.pid, ProposalID: found.ProposalID, Accept: true})
+	e.Send(mm, &MatchResponse{PID: b.pid, ProposalID: found.ProposalID, Accept: true})
+	a.expect(t, "matched", queueState("matched"))
+
+	// a entra nel party di leader mentre gioca
+	e.Send(parties, &PartyCommand{From: leader.pid, Action: "party_invite", PlayerID: playerID(a.pid)})
+	e.Send(parties, &PartyCommand{From: a.pid, Action: "party_accept"})
+	leader.expect(t, "party_update", func(msg ServerMessage) bool {
+		update, ok := msg.(*PartyUpdateMsg)
+		return ok && len(update.Members) == 2
+	})
+
+	e.Send(mm, &MatchResult{
+		Mode:   Duel,
+		Teams:  map[Team][]*actor.PID{TeamLawmen: {a.pid}, TeamOutlaws: {b.pid}},
+		Scores: map[Team]int{TeamLawmen: 3, TeamOutlaws: 1},
+	})
+
+	// a non torna in coda col gruppo vecchio, b sì
+	lobby := a.expect(t, "lobby", queueState("lobby")).(*QueueStatusMsg)
+	if lobby.Reason != "party_changed" {
+		t.Errorf("a back to the lobby for %q, want party_changed", lobby.Reason)
+	}
+	a.seen = nil
+	b.expect(t, "queued", queueState("queued"))
+	if a.got(queueState("queued")) {
+		t.Error("a requeued with its old group")
+	}
+}
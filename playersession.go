@@ -1,8 +1,11 @@
 package main
 
 import (
-	"encoding/json"
+	"encoding/hex"
+	"fmt"
 	"log"
+	"net"
+	"time"
 
 	"github.com/anthdm/hollywood/actor"
 	"github.com/gorilla/websocket"
@@ -10,14 +13,29 @@ import (
 
 type PlayerSession struct {
 	conn        *websocket.Conn
+	writer      *writePump
 	matchmaking *actor.PID
+	parties     *actor.PID
 	sessionPID  *actor.PID
 	matchPID    *actor.PID
+
+	// Handshake: version resta 0 finché non parte il "welcome"
+	hello      *HelloMsg
+	version    int
+	codec      Codec
+	registered bool
+
+	// Endpoint UDP, legato alla sessione dal token del welcome
+	udp      *actor.PID
+	udpPort  int
+	udpToken string
+	udpConn  *net.UDPConn
+	udpAddr  *net.UDPAddr
 }
 
 func NewSession(conn *websocket.Conn) actor.Producer {
 	return func() actor.Receiver {
-		return &PlayerSession{conn: conn}
+		return &PlayerSession{conn: conn, writer: newWritePump(conn), codec: jsonCodec{}}
 	}
 }
 
@@ -25,69 +43,341 @@ func (ps *PlayerSession) Receive(c *actor.Context) {
 	switch msg := c.Message().(type) {
 	case actor.Started:
 		ps.sessionPID = c.PID()
+		go ps.writer.run()
 		go ps.readLoop(c)
 
-	case *actor.PID:
-		// Ricevi il PID del matchmaking e registrati
-		ps.matchmaking = msg
-		c.Send(ps.matchmaking, ps.sessionPID)
-		log.Println("Registrato al matchmaking:", ps.sessionPID.String())
+	case *Lobby:
+		// Ricevi i PID di matchmaking, party e UDP
+		ps.matchmaking = msg.Matchmaking
+		ps.parties = msg.Parties
+		ps.udp = msg.UDP
+		ps.udpPort = msg.UDPPort
+		ps.register(c)
+
+	case actor.Stopped:
+		// Disconnesso: fuori dalla coda, dal party e dal match in corso
+		if ps.matchPID != nil {
+			c.Send(ps.matchPID, &PlayerLeft{PID: ps.sessionPID})
+		}
+		if ps.registered {
+			c.Send(ps.matchmaking, &QueueLeave{PID: ps.sessionPID, Disconnected: true})
+			c.Send(ps.parties, &SessionOffline{PID: ps.sessionPID})
+		}
+		if ps.udpToken != "" {
+			c.Send(ps.udp, &UDPUnregister{Token: ps.udpToken})
+		}
+		ps.writer.close()
+
+	case *clientFrame:
+		ps.handleClientFrame(c, msg)
+
+	case *udpFrame:
+		ps.handleUDPFrame(c, msg)
+
+	case *MatchJoined:
+		ps.matchPID = msg.Match
+		log.Printf("Player %s entrato nel match %s", ps.sessionPID.String(), msg.Match.String())
+		ps.send(&MatchJoinedMsg{
+			Team:     msg.Team.String(),
+			Mode:     msg.Mode.String(),
+			PlayerID: msg.PlayerID,
+			Rating:   msg.Rating,
+			Roster:   msg.Roster,
+			Ratings:  msg.Ratings,
+		})
 
-	case *PlayerAction:
+	case *MatchLeft:
+		if ps.matchPID == nil || ps.matchPID.String() != msg.Match.String() {
+			return
+		}
+		ps.matchPID = nil
+		ps.send(&MatchLeftMsg{Reason: msg.Reason})
+
+	case ServerMessage:
 		// Messaggio da inoltrare al client Unity
-		ps.conn.WriteMessage(websocket.TextMessage, []byte(msg.Data))
+		ps.send(msg)
+	}
+}
 
+// register answers "hello" and joins the lobby once the client sent it and
+// the Server sent the lobby PIDs, whichever comes last.
+func (ps *PlayerSession) register(c *actor.Context) {
+	if ps.registered || ps.hello == nil || ps.matchmaking == nil {
+		return
 	}
+	ps.registered = true
+
+	codec := negotiateCodec(ps.hello.Encodings)
+	ps.version = min(ps.hello.Version, ProtocolVersion)
+	welcome := &WelcomeMsg{
+		Version:  ps.version,
+		Encoding: codec.Name(),
+		PlayerID: playerID(ps.sessionPID),
+	}
+	if ps.udp != nil {
+		ps.udpToken = newUDPToken()
+		c.Send(ps.udp, &UDPRegister{Token: ps.udpToken, Session: ps.sessionPID})
+		welcome.UDPToken = hex.EncodeToString([]byte(ps.udpToken))
+		welcome.UDPPort = ps.udpPort
+	}
+	ps.send(welcome)
+	// Il welcome parte ancora in JSON, da qui in poi il codec scelto
+	ps.codec = codec
+
+	online := &SessionOnline{PID: ps.sessionPID, Identity: ps.identity()}
+	c.Send(ps.parties, online)
+	c.Send(ps.matchmaking, online)
+	// Resta nella lobby finché non manda "queue_join"
+	log.Println("Sessione in lobby:", ps.sessionPID.String())
 }
 
+// identity is who the player is beyond this connection: the account sent
+// in hello or, without one, the remote address.
+func (ps *PlayerSession) identity() string {
+	if ps.hello.AccountID != "" {
+		return "account:" + ps.hello.AccountID
+	}
+	host, _, err := net.SplitHostPort(ps.conn.RemoteAddr().String())
+	if err != nil {
+		return ""
+	}
+	return "addr:" + host
+}
+
+// send hands msg to the write pump, never blocking the actor. Unreliable
+// messages go as a datagram once the client bound its UDP endpoint, and
+// otherwise replace the stale one still waiting for the websocket.
+func (ps *PlayerSession) send(msg ServerMessage) {
+	data, err := ps.codec.Encode(msg)
+	if err != nil {
+		log.Printf("Errore encoding %s: %v", msg.ActionName(), err)
+		return
+	}
+	if _, ok := msg.(unreliableMessage); ok {
+		if ps.udpAddr != nil && len(data) <= maxDatagramSize {
+			if _, err := ps.udpConn.WriteToUDP(data, ps.udpAddr); err == nil {
+				return
+			}
+		}
+		ps.writer.sendUnreliable(ps.codec.FrameType(), data)
+		return
+	}
+	ps.writer.send(ps.codec.FrameType(), data)
+}
+
+// sendError reports a refused client message; the session stays open.
+func (ps *PlayerSession) sendError(err *ProtocolError) {
+	ps.send(&ErrorMsg{Code: err.Code, Message: err.Message, Request: err.Request})
+}
+
+// readLoop only reads frames: they are handled inside Receive, so the
+// session state (e.g. matchPID) is never touched from this goroutine. A
+// client that stops answering the pings times out here.
 func (ps *PlayerSession) readLoop(c *actor.Context) {
+	ps.conn.SetReadDeadline(time.Now().Add(pongWait))
+	ps.conn.SetPongHandler(func(string) error {
+		return ps.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
 	for {
-		_, data, err := ps.conn.ReadMessage()
+		frameType, data, err := ps.conn.ReadMessage()
 		if err != nil {
 			log.Println("WS read error:", err)
 			c.Engine().Poison(ps.sessionPID)
 			return
 		}
+		c.Engine().Send(ps.sessionPID, &clientFrame{Type: frameType, Data: data})
+	}
+}
 
-		var m struct {
-			Action string `json:"action"`
-		}
-		if err := json.Unmarshal(data, &m); err != nil {
-			log.Println("JSON Unmarshal error:", err, "Data:", string(data))
-			// In caso di JSON errato, chiudi la connessione per evitare problemi di protocollo
-			c.Engine().Poison(ps.sessionPID)
+// handleClientFrame decodes a websocket frame of the client with the session
+// codec, runs the handshake and routes the message. Invalid messages get an
+// "error" reply.
+func (ps *PlayerSession) handleClientFrame(c *actor.Context, frame *clientFrame) {
+	if frame.Type != ps.codec.FrameType() {
+		ps.sendError(&ProtocolError{
+			Code:    errMalformed,
+			Message: fmt.Sprintf("unexpected frame type %d for %s", frame.Type, ps.codec.Name()),
+		})
+		return
+	}
+
+	msg, err := ps.codec.Decode(frame.Data)
+	if err != nil {
+		log.Printf("Messaggio rifiutato da %s: %v", ps.sessionPID.String(), err)
+		ps.sendError(err.(*ProtocolError))
+		return
+	}
+	action := msg.ActionName()
+
+	log.Printf("Ricevuto action: %s dal player: %s", action, ps.sessionPID.String())
+
+	if hello, ok := msg.(*HelloMsg); ok {
+		if ps.hello != nil {
+			ps.sendError(&ProtocolError{Code: errHandshakeDone, Message: "hello already received", Request: action})
 			return
 		}
+		ps.hello = hello
+		ps.register(c)
+		return
+	}
+	if ps.version == 0 {
+		ps.sendError(&ProtocolError{Code: errHandshakeRequired, Message: "send hello first", Request: action})
+		return
+	}
+	if _, ok := msg.(*UDPBindMsg); ok {
+		ps.sendError(&ProtocolError{Code: errWrongTransport, Message: "send it over UDP", Request: action})
+		return
+	}
 
-		log.Printf("Ricevuto action: %s dal player: %s", m.Action, ps.sessionPID.String())
+	ps.route(c, msg)
+}
 
-		if ps.matchPID == nil {
-			log.Println("Nessun match assegnato, ignoro action:", m.Action)
-			continue
-		}
+// handleUDPFrame handles a datagram of the client. The first valid one binds
+// the UDP endpoint, a later one from another address moves it (e.g. after a
+// NAT rebinding): the token already proved who sent it.
+func (ps *PlayerSession) handleUDPFrame(c *actor.Context, frame *udpFrame) {
+	msg, err := ps.codec.Decode(frame.Data)
+	if err != nil {
+		ps.sendError(err.(*ProtocolError))
+		return
+	}
+	action := msg.ActionName()
+	if !udpActions[action] {
+		ps.sendError(&ProtocolError{Code: errWrongTransport, Message: "send it over the websocket", Request: action})
+		return
+	}
 
-		// Supporta tutte le azioni di gameplay
-		switch m.Action {
-		case "login", "shoot", "move", "buy_weapon", "plant_bomb", "defuse_bomb", "explosion_damage":
-			c.Send(ps.matchPID, &PlayerAction{
-				From:   ps.sessionPID,
-				Action: m.Action,
-				Data:   string(data),
-			})
-		default:
-			log.Printf("Azione non gestita dal server: %s", m.Action)
+	if ps.udpAddr == nil || ps.udpAddr.String() != frame.Addr.String() {
+		bound := ps.udpAddr == nil
+		ps.udpConn, ps.udpAddr = frame.Conn, frame.Addr
+		log.Printf("Player %s su UDP da %s", ps.sessionPID.String(), frame.Addr.String())
+		if bound {
+			ps.send(&UDPBoundMsg{})
 		}
 	}
+
+	if _, ok := msg.(*UDPBindMsg); ok {
+		return
+	}
+	ps.route(c, msg)
 }
 
+// route hands a decoded message to the lobby actors or to the current match.
+func (ps *PlayerSession) route(c *actor.Context, msg ClientMessage) {
+	action := msg.ActionName()
+
+	// Azioni di lobby, gestite prima di entrare in un match
+	switch msg := msg.(type) {
+	case *QueueJoinMsg:
+		// Passa dai party: il leader mette in coda tutto il gruppo
+		c.Send(ps.parties, &QueueRequest{
+			PID:      ps.sessionPID,
+			Mode:     msg.GameMode(),
+			TeamSize: msg.TeamSize,
+			Requeue:  msg.Requeue,
+		})
+		return
+	case *QueueLeaveMsg:
+		c.Send(ps.matchmaking, &QueueLeave{PID: ps.sessionPID})
+		return
+	case *MatchReplyMsg:
+		c.Send(ps.matchmaking, &MatchResponse{
+			PID:        ps.sessionPID,
+			ProposalID: msg.ProposalID,
+			Accept:     msg.Accept(),
+		})
+		return
+	case *PartyMsg:
+		c.Send(ps.parties, &PartyCommand{From: ps.sessionPID, Action: action, PlayerID: msg.PlayerID})
+		return
+	}
+
+	// Tutto il resto è gameplay
+	if ps.matchPID == nil {
+		ps.sendError(&ProtocolError{Code: errNotInMatch, Message: "no match assigned", Request: action})
+		return
+	}
+	c.Send(ps.matchPID, &PlayerAction{From: ps.sessionPID, Message: msg})
+}
+
+// MatchJoined is sent by a Match to each of its players when it starts:
+// the session routes gameplay actions to Match from then on.
+type MatchJoined struct {
+	Match    *actor.PID
+	Team     Team
+	Mode     GameModeType
+	PlayerID string
+	Rating   int
+	Roster   map[string][]string // team name -> player ids
+	Ratings  map[string]int      // player id -> rating
+}
+
+// MatchLeft is sent by a Match to its players when it ends.
+type MatchLeft struct {
+	Match  *actor.PID
+	Reason string
+}
+
+// PlayerLeft tells a Match that one of its players disconnected.
+type PlayerLeft struct {
+	PID *actor.PID
+}
+
+// Frame letto dal websocket, consegnato alla sessione stessa
+type clientFrame struct {
+	Type int // websocket.TextMessage o BinaryMessage
+	Data []byte
+}
+
+// Azione di gameplay del client, già validata, inoltrata al Match
 type PlayerAction struct {
-	From   *actor.PID
-	Action string
-	Data   string
+	From    *actor.PID
+	Message ClientMessage
+}
+
+// notifyPlayer writes msg to the client of pid through its PlayerSession.
+func notifyPlayer(c *actor.Context, pid *actor.PID, msg ServerMessage) {
+	c.Send(pid, msg)
+}
+
+// PID dei servizi di lobby, mandati dal Server a ogni nuova sessione
+type Lobby struct {
+	Matchmaking *actor.PID
+	Parties     *actor.PID
+	UDP         *actor.PID // nil se il socket UDP non è in ascolto
+	UDPPort     int
+}
+
+// Richiesta di entrare in coda per una modalità. Con Requeue il giocatore
+// torna in coda da solo a fine match, altrimenti torna alla lobby. Party
+// contiene tutti i membri quando è il leader a mettere in coda il gruppo.
+// TeamSize 0 lascia scegliere a Matchmaking in base al party.
+type QueueRequest struct {
+	PID      *actor.PID
+	Mode     GameModeType
+	TeamSize int
+	Requeue  bool
+	Party    []*actor.PID
+	PartyID  int
+}
+
+// Uscita dalla coda, esplicita o per disconnessione
+type QueueLeave struct {
+	PID          *actor.PID
+	Disconnected bool
 }
 
 // Stato giocatore per matchmaking
 type PlayerStatus struct {
-	PID  *actor.PID
-	Free bool
+	PID      *actor.PID
+	Free     bool
+	Mode     GameModeType
+	TeamSize int // giocatori per squadra della coda scelta
+	Rating   int
+	QueuedAt time.Time
+	Requeue  bool
+	PartyID  int
+	Proposal int  // ready check in corso, 0 se nessuno
+	Regroup  bool // party cambiato durante ready check o match
 }
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+const (
+	gravity = 9.81
+
+	// Rimbalzo sul terreno
+	groundRestitution = 0.3
+	groundFriction    = 0.6
+)
+
+// Projectile is a thrown explosive simulated by the Match tick.
+type Projectile struct {
+	ID       int
+	Owner    *actor.PID
+	Weapon   *Weapon
+	Position Position
+	Velocity Position
+	FuseAt   time.Time
+}
+
+func (p *Projectile) entityID() string {
+	return fmt.Sprintf("dynamite:%d", p.ID)
+}
+
+// throwSpeed is the launch speed that reaches weapon.Range on flat ground
+// when thrown at 45 degrees.
+func throwSpeed(weapon *Weapon) float64 {
+	return math.Sqrt(gravity * weapon.Range)
+}
+
+func (m *Match) handleThrowDynamite(c *actor.Context, thrower *actor.PID, msg *ThrowDynamiteMsg) {
+	if !m.playersAlive[thrower] {
+		return
+	}
+
+	state, ok := m.playerStates[thrower]
+	if !ok {
+		return
+	}
+
+	// Candelotti a parte: l'arma in mano resta quella che è
+	playerWeapons := m.playerWeapons[thrower]
+	if playerWeapons.Dynamite <= 0 {
+		return
+	}
+
+	weapon := WeaponStats[WeaponDynamite]
+	now := time.Now()
+	if now.Sub(m.lastThrow[thrower]) < weapon.FireRate {
+		return
+	}
+
+	direction := msg.Direction().Normalize()
+
+	playerWeapons.Dynamite--
+	m.lastThrow[thrower] = now
+
+	m.nextProjectileID++
+	projectile := &Projectile{
+		ID:       m.nextProjectileID,
+		Owner:    thrower,
+		Weapon:   weapon,
+		Position: EyePosition(state.Position, state.Stance),
+		Velocity: direction.Scale(throwSpeed(weapon)).Add(state.Velocity),
+		FuseAt:   now.Add(weapon.Fuse),
+	}
+	m.projectiles = append(m.projectiles, projectile)
+
+	m.broadcast(c, &DynamiteThrownMsg{
+		ID:     projectile.ID,
+		Owner:  playerID(thrower),
+		X:      projectile.Position.X,
+		Y:      projectile.Position.Y,
+		Z:      projectile.Position.Z,
+		VX:     projectile.Velocity.X,
+		VY:     projectile.Velocity.Y,
+		VZ:     projectile.Velocity.Z,
+		FuseMs: weapon.Fuse.Milliseconds(),
+	})
+}
+
+// updateProjectiles integrates the arc of every projectile over dt and
+// detonates the ones whose fuse ran out.
+func (m *Match) updateProjectiles(c *actor.Context, now time.Time, dt float64) {
+	remaining := m.projectiles[:0]
+	var detonated []*Projectile
+
+	for _, p := range m.projectiles {
+		if !now.Before(p.FuseAt) {
+			detonated = append(detonated, p)
+			continue
+		}
+
+		p.Velocity.Y -= gravity * dt
+		next := p.Position.Add(p.Velocity.Scale(dt))
+
+		// Contro un edificio si ferma dove si trova
+		if m.gameMap.LineBlocked(p.Position, next) {
+			p.Velocity = Position{}
+			next = p.Position
+		}
+
+		if next.Y <= 0 {
+			next.Y = 0
+			p.Velocity.Y = -p.Velocity.Y * groundRestitution
+			p.Velocity.X *= groundFriction
+			p.Velocity.Z *= groundFriction
+		}
+
+		p.Position = next
+		remaining = append(remaining, p)
+	}
+	m.projectiles = remaining
+
+	// Detonate after the list is settled: a detonation may end the round
+	for _, p := range detonated {
+		if m.phase != PhaseActive {
+			break
+		}
+		m.detonateProjectile(c, p, now)
+	}
+}
+
+func (m *Match) detonateProjectile(c *actor.Context, p *Projectile, now time.Time) {
+	m.broadcast(c, &DynamiteExplodedMsg{
+		ID:     p.ID,
+		X:      p.Position.X,
+		Y:      p.Position.Y,
+		Z:      p.Position.Z,
+		Radius: p.Weapon.BlastRadius,
+	})
+
+	m.detonate(c, Explosion{
+		Position:   p.Position,
+		Radius:     p.Weapon.BlastRadius,
+		Damage:     p.Weapon.Damage,
+		Time:       now,
+		OwnerPID:   p.Owner,
+		Linger:     dynamiteLinger,
+		BurnDamage: dynamiteBurnDamage,
+	})
+}
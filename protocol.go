@@ -0,0 +1,407 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Versione del protocollo client/server. Il client la dichiara con "hello"
+// e il server risponde con la versione usata, la più alta supportata da
+// entrambi.
+const (
+	ProtocolVersion    = 1
+	minProtocolVersion = 1
+)
+
+// Codici degli errori di protocollo, mandati al client con "error"
+const (
+	errMalformed          = "malformed"
+	errUnknownAction      = "unknown_action"
+	errInvalidField       = "invalid_field"
+	errHandshakeRequired  = "handshake_required"
+	errHandshakeDone      = "handshake_done"
+	errUnsupportedVersion = "unsupported_version"
+	errNotInMatch         = "not_in_match"
+	errWrongTransport     = "wrong_transport"
+)
+
+// ProtocolError is a client message the server refused, reported back to
+// the client instead of closing the connection.
+type ProtocolError struct {
+	Code    string
+	Message string
+	Request string // action of the refused message, when known
+}
+
+func (e *ProtocolError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func invalidField(field, reason string) error {
+	return &ProtocolError{Code: errInvalidField, Message: field + " " + reason}
+}
+
+// ClientMessage is a message sent by the client, decoded and validated by
+// decodeClientMessage.
+type ClientMessage interface {
+	ActionName() string
+	Validate() error
+}
+
+// clientHeader is embedded by every client message: some actions share the
+// same struct (e.g. "match_accept" and "match_decline").
+type clientHeader struct {
+	Action string `json:"action"`
+}
+
+func (h clientHeader) ActionName() string { return h.Action }
+
+// Messaggi del client, registrati per action
+var clientMessages = map[string]func() ClientMessage{
+	"hello": func() ClientMessage { return &HelloMsg{} },
+
+	"queue_join":    func() ClientMessage { return &QueueJoinMsg{} },
+	"select_mode":   func() ClientMessage { return &QueueJoinMsg{} },
+	"queue_leave":   func() ClientMessage { return &QueueLeaveMsg{} },
+	"match_accept":  func() ClientMessage { return &MatchReplyMsg{} },
+	"match_decline": func() ClientMessage { return &MatchReplyMsg{} },
+
+	"party_create":  func() ClientMessage { return &PartyMsg{} },
+	"party_invite":  func() ClientMessage { return &PartyMsg{} },
+	"party_accept":  func() ClientMessage { return &PartyMsg{} },
+	"party_decline": func() ClientMessage { return &PartyMsg{} },
+	"party_leave":   func() ClientMessage { return &PartyMsg{} },
+
+	"shoot":            func() ClientMessage { return &ShootMsg{} },
+	"move":             func() ClientMessage { return &MoveMsg{} },
+	"buy_weapon":       func() ClientMessage { return &BuyWeaponMsg{} },
+	"select_loadout":   func() ClientMessage { return &SelectLoadoutMsg{} },
+	"plant_bomb":       func() ClientMessage { return &PlantBombMsg{} },
+	"defuse_bomb":      func() ClientMessage { return &DefuseBombMsg{} },
+	"throw_dynamite":   func() ClientMessage { return &ThrowDynamiteMsg{} },
+	"explosion_damage": func() ClientMessage { return &ExplosionDamageMsg{} },
+	"snapshot_ack":     func() ClientMessage { return &SnapshotAckMsg{} },
+
+	"udp_bind": func() ClientMessage { return &UDPBindMsg{} },
+}
+
+// decodeClientMessage is the only place client frames are parsed, whatever
+// the Codec: the action selects the struct, unknown fields are refused and
+// the message is validated before anyone else sees it. unmarshal decodes
+// data into v, rejecting unknown fields when strict. Errors are
+// *ProtocolError.
+func decodeClientMessage(data []byte, unmarshal func(data []byte, v interface{}, strict bool) error) (ClientMessage, error) {
+	var header clientHeader
+	if err := unmarshal(data, &header, false); err != nil {
+		return nil, &ProtocolError{Code: errMalformed, Message: err.Error()}
+	}
+	if header.Action == "" {
+		return nil, &ProtocolError{Code: errMalformed, Message: "missing action"}
+	}
+	newMsg, ok := clientMessages[header.Action]
+	if !ok {
+		return nil, &ProtocolError{Code: errUnknownAction, Message: "unknown action", Request: header.Action}
+	}
+
+	msg := newMsg()
+	if err := unmarshal(data, msg, true); err != nil {
+		return nil, &ProtocolError{Code: errInvalidField, Message: err.Error(), Request: header.Action}
+	}
+
+	if err := msg.Validate(); err != nil {
+		var protoErr *ProtocolError
+		if !errors.As(err, &protoErr) {
+			protoErr = &ProtocolError{Code: errInvalidField, Message: err.Error()}
+		}
+		protoErr.Request = header.Action
+		return nil, protoErr
+	}
+	return msg, nil
+}
+
+// Handshake
+
+// HelloMsg opens the connection, always as JSON. Encodings lists the codecs
+// the client supports, preferred first. AccountID identifies the player
+// across reconnections; without it the server falls back to its address.
+type HelloMsg struct {
+	clientHeader
+	Version   int      `json:"version"`
+	Encodings []string `json:"encodings,omitempty"`
+	AccountID string   `json:"account_id,omitempty"`
+}
+
+const maxAccountIDLength = 64
+
+func (m *HelloMsg) Validate() error {
+	if m.Version < minProtocolVersion {
+		return &ProtocolError{
+			Code:    errUnsupportedVersion,
+			Message: fmt.Sprintf("version %d, server supports %d to %d", m.Version, minProtocolVersion, ProtocolVersion),
+		}
+	}
+	if len(m.AccountID) > maxAccountIDLength {
+		return invalidField("account_id", fmt.Sprintf("longer than %d bytes", maxAccountIDLength))
+	}
+	return nil
+}
+
+// Lobby
+
+// QueueJoinMsg is "queue_join", or its older name "select_mode".
+type QueueJoinMsg struct {
+	clientHeader
+	Mode     string `json:"mode"`
+	TeamSize int    `json:"team_size,omitempty"` // 0: quanto basta per il party
+	Requeue  bool   `json:"requeue"`
+
+	mode GameModeType
+}
+
+func (m *QueueJoinMsg) Validate() error {
+	mode, ok := ParseGameModeType(m.Mode)
+	if !ok {
+		return invalidField("mode", fmt.Sprintf("unknown mode %q", m.Mode))
+	}
+	if m.TeamSize != 0 && (m.TeamSize < minTeamSize || m.TeamSize > maxTeamSize) {
+		return invalidField("team_size", fmt.Sprintf("must be between %d and %d", minTeamSize, maxTeamSize))
+	}
+	m.mode = mode
+	return nil
+}
+
+// GameMode returns the validated mode.
+func (m *QueueJoinMsg) GameMode() GameModeType {
+	return m.mode
+}
+
+type QueueLeaveMsg struct {
+	clientHeader
+}
+
+func (m *QueueLeaveMsg) Validate() error { return nil }
+
+// MatchReplyMsg is "match_accept" or "match_decline" for a "match_found".
+type MatchReplyMsg struct {
+	clientHeader
+	ProposalID int `json:"proposal_id"`
+}
+
+func (m *MatchReplyMsg) Validate() error {
+	if m.ProposalID <= 0 {
+		return invalidField("proposal_id", "must be positive")
+	}
+	return nil
+}
+
+func (m *MatchReplyMsg) Accept() bool {
+	return m.Action == "match_accept"
+}
+
+// PartyMsg is any of the "party_*" commands.
+type PartyMsg struct {
+	clientHeader
+	PlayerID string `json:"player_id,omitempty"`
+}
+
+func (m *PartyMsg) Validate() error {
+	if m.Action == "party_invite" && m.PlayerID == "" {
+		return invalidField("player_id", "is required")
+	}
+	return nil
+}
+
+// Gameplay, inoltrati al Match
+
+type ShootMsg struct {
+	clientHeader
+	OriginX float64 `json:"originX"`
+	OriginY float64 `json:"originY"`
+	OriginZ float64 `json:"originZ"`
+	DirX    float64 `json:"dirX"`
+	DirY    float64 `json:"dirY"`
+	DirZ    float64 `json:"dirZ"`
+}
+
+func (m *ShootMsg) Origin() Position {
+	return Position{X: m.OriginX, Y: m.OriginY, Z: m.OriginZ}
+}
+
+func (m *ShootMsg) Direction() Position {
+	return Position{X: m.DirX, Y: m.DirY, Z: m.DirZ}
+}
+
+func (m *ShootMsg) Validate() error {
+	if !finite(m.OriginX, m.OriginY, m.OriginZ) {
+		return invalidField("origin", "is not a finite position")
+	}
+	if !finite(m.DirX, m.DirY, m.DirZ) || m.Direction().Length() == 0 {
+		return invalidField("dir", "is not a direction")
+	}
+	return nil
+}
+
+// MoveMsg is the client movement input: the position is required, the rest
+// defaults to zero.
+type MoveMsg struct {
+	clientHeader
+	X      *float64 `json:"x"`
+	Y      *float64 `json:"y"`
+	Z      *float64 `json:"z"`
+	VX     float64  `json:"vx"`
+	VY     float64  `json:"vy"`
+	VZ     float64  `json:"vz"`
+	Yaw    float64  `json:"yaw"`
+	Pitch  float64  `json:"pitch"`
+	Seq    int      `json:"seq"`
+	Stance string   `json:"stance"`
+}
+
+func (m *MoveMsg) Validate() error {
+	if m.X == nil || m.Y == nil || m.Z == nil {
+		return invalidField("x, y, z", "are required")
+	}
+	if !finite(*m.X, *m.Y, *m.Z) {
+		return invalidField("x, y, z", "are not a finite position")
+	}
+	if !finite(m.VX, m.VY, m.VZ, m.Yaw, m.Pitch) {
+		return invalidField("vx, vy, vz, yaw, pitch", "must be finite")
+	}
+	if m.Seq < 0 {
+		return invalidField("seq", "must not be negative")
+	}
+	switch m.Stance {
+	case "", StanceStanding.String(), StanceCrouching.String():
+	default:
+		return invalidField("stance", fmt.Sprintf("unknown stance %q", m.Stance))
+	}
+	return nil
+}
+
+// Input returns the validated move as a MoveInput.
+func (m *MoveMsg) Input() MoveInput {
+	in := MoveInput{
+		Position: Position{X: *m.X, Y: *m.Y, Z: *m.Z},
+		Velocity: Position{X: m.VX, Y: m.VY, Z: m.VZ},
+		Yaw:      m.Yaw,
+		Pitch:    m.Pitch,
+		Seq:      m.Seq,
+	}
+	if m.Stance == StanceCrouching.String() {
+		in.Stance = StanceCrouching
+	}
+	return in
+}
+
+type BuyWeaponMsg struct {
+	clientHeader
+	WeaponType *WeaponType `json:"weapon_type"`
+}
+
+func (m *BuyWeaponMsg) Validate() error {
+	return validateWeapon(m.WeaponType)
+}
+
+type SelectLoadoutMsg struct {
+	clientHeader
+	WeaponType *WeaponType `json:"weapon_type"`
+}
+
+func (m *SelectLoadoutMsg) Validate() error {
+	return validateWeapon(m.WeaponType)
+}
+
+type PlantBombMsg struct {
+	clientHeader
+}
+
+func (m *PlantBombMsg) Validate() error { return nil }
+
+type DefuseBombMsg struct {
+	clientHeader
+}
+
+func (m *DefuseBombMsg) Validate() error { return nil }
+
+type ThrowDynamiteMsg struct {
+	clientHeader
+	DirX float64 `json:"dirX"`
+	DirY float64 `json:"dirY"`
+	DirZ float64 `json:"dirZ"`
+}
+
+func (m *ThrowDynamiteMsg) Direction() Position {
+	return Position{X: m.DirX, Y: m.DirY, Z: m.DirZ}
+}
+
+func (m *ThrowDynamiteMsg) Validate() error {
+	if !finite(m.DirX, m.DirY, m.DirZ) || m.Direction().Length() == 0 {
+		return invalidField("dir", "is not a direction")
+	}
+	return nil
+}
+
+// ExplosionDamageMsg is accepted only with MatchConfig.Debug.
+type ExplosionDamageMsg struct {
+	clientHeader
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Z      float64 `json:"z"`
+	Radius float64 `json:"radius"`
+	Damage int     `json:"damage"`
+}
+
+func (m *ExplosionDamageMsg) Validate() error {
+	if !finite(m.X, m.Y, m.Z, m.Radius) {
+		return invalidField("x, y, z, radius", "must be finite")
+	}
+	if m.Radius <= 0 {
+		return invalidField("radius", "must be positive")
+	}
+	if m.Damage < 0 {
+		return invalidField("damage", "must not be negative")
+	}
+	return nil
+}
+
+type SnapshotAckMsg struct {
+	clientHeader
+	Seq *int `json:"seq"`
+}
+
+func (m *SnapshotAckMsg) Validate() error {
+	if m.Seq == nil {
+		return invalidField("seq", "is required")
+	}
+	if *m.Seq <= 0 {
+		return invalidField("seq", "must be positive")
+	}
+	return nil
+}
+
+// UDPBindMsg only binds the UDP endpoint of the session, see udpFrame.
+type UDPBindMsg struct {
+	clientHeader
+}
+
+func (m *UDPBindMsg) Validate() error { return nil }
+
+func validateWeapon(weaponType *WeaponType) error {
+	if weaponType == nil {
+		return invalidField("weapon_type", "is required")
+	}
+	if WeaponStats[*weaponType] == nil {
+		return invalidField("weapon_type", fmt.Sprintf("unknown weapon %d", *weaponType))
+	}
+	return nil
+}
+
+func finite(values ...float64) bool {
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// Elo: ogni giocatore parte da 1500
+const (
+	defaultRating = 1500
+	eloK          = 32.0
+)
+
+// Finestra di rating accettata, si allarga con l'attesa in coda
+const (
+	ratingWindowBase   = 100
+	ratingWindowGrowth = 10 // per second of waiting
+	ratingWindowMax    = 1000
+)
+
+// ratingWindow is the largest rating gap player accepts after waiting
+// since QueuedAt.
+func (p *PlayerStatus) ratingWindow(now time.Time) int {
+	waited := int(now.Sub(p.QueuedAt).Seconds())
+	return min(ratingWindowMax, ratingWindowBase+ratingWindowGrowth*waited)
+}
+
+// MatchResult is sent by a Match to Matchmaking when it ends. Scores rank
+// the teams: the higher the better, equal scores are a draw.
+type MatchResult struct {
+	Mode   GameModeType
+	Teams  map[Team][]*actor.PID
+	Scores map[Team]int
+}
+
+func expectedScore(rating, opponent float64) float64 {
+	return 1 / (1 + math.Pow(10, (opponent-rating)/400))
+}
+
+// ratingChanges computes the Elo update of every team of a result: each
+// team plays a game against every other one, rated on the team average.
+// Every player of a team gets the change of its team.
+func ratingChanges(result *MatchResult, ratings map[string]int) map[Team]int {
+	average := make(map[Team]float64, len(result.Teams))
+	for team, players := range result.Teams {
+		if len(players) == 0 {
+			continue
+		}
+		total := 0
+		for _, pid := range players {
+			total += ratings[pid.String()]
+		}
+		average[team] = float64(total) / float64(len(players))
+	}
+
+	changes := make(map[Team]int, len(average))
+	if len(average) < 2 {
+		return changes
+	}
+	for team, rating := range average {
+		delta := 0.0
+		for opponent, opponentRating := range average {
+			if opponent == team {
+				continue
+			}
+			actual := 0.5
+			if result.Scores[team] > result.Scores[opponent] {
+				actual = 1
+			} else if result.Scores[team] < result.Scores[opponent] {
+				actual = 0
+			}
+			delta += actual - expectedScore(rating, opponentRating)
+		}
+		changes[team] = int(math.Round(eloK * delta / float64(len(average)-1)))
+	}
+	return changes
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+func TestRatingChanges(t *testing.T) {
+	pids := make(map[string]*actor.PID)
+	pid := func(name string) *actor.PID {
+		if pids[name] == nil {
+			pids[name] = actor.NewPID("local", name)
+		}
+		return pids[name]
+	}
+	ratings := map[string]int{
+		pid("a").String():    1500,
+		pid("b").String():    1500,
+		pid("c").String():    1500,
+		pid("d").String():    1500,
+		pid("low").String():  1400,
+		pid("high").String(): 1600,
+	}
+
+	tests := []struct {
+		name   string
+		teams  map[Team][]*actor.PID
+		scores map[Team]int
+		want   map[Team]int
+	}{
+		{"even win",
+			map[Team][]*actor.PID{TeamLawmen: {pid("a")}, TeamOutlaws: {pid("b")}},
+			map[Team]int{TeamLawmen: 3, TeamOutlaws: 1},
+			map[Team]int{TeamLawmen: 16, TeamOutlaws: -16}},
+		{"draw between equals",
+			map[Team][]*actor.PID{TeamLawmen: {pid("a")}, TeamOutlaws: {pid("b")}},
+			map[Team]int{TeamLawmen: 2, TeamOutlaws: 2},
+			map[Team]int{TeamLawmen: 0, TeamOutlaws: 0}},
+		{"upset pays more",
+			map[Team][]*actor.PID{TeamLawmen: {pid("low")}, TeamOutlaws: {pid("high")}},
+			map[Team]int{TeamLawmen: 1, TeamOutlaws: 0},
+			map[Team]int{TeamLawmen: 24, TeamOutlaws: -24}},
+		{"favourite wins less",
+			map[Team][]*actor.PID{TeamLawmen: {pid("low")}, TeamOutlaws: {pid("high")}},
+			map[Team]int{TeamLawmen: 0, TeamOutlaws: 1},
+			map[Team]int{TeamLawmen: -8, TeamOutlaws: 8}},
+		{"teams rated on their average",
+			map[Team][]*actor.PID{TeamLawmen: {pid("low"), pid("high")}, TeamOutlaws: {pid("a"), pid("b")}},
+			map[Team]int{TeamLawmen: 10, TeamOutlaws: 5},
+			map[Team]int{TeamLawmen: 16, TeamOutlaws: -16}},
+		{"forfeit loses",
+			map[Team][]*actor.PID{TeamLawmen: {pid("a")}, TeamOutlaws: {pid("b")}},
+			map[Team]int{TeamLawmen: 0, TeamOutlaws: -1},
+			map[Team]int{TeamLawmen: 16, TeamOutlaws: -16}},
+		{"free for all against everyone",
+			map[Team][]*actor.PID{SoloTeam(0): {pid("a")}, SoloTeam(1): {pid("b")}, SoloTeam(2): {pid("c")}, SoloTeam(3): {pid("d")}},
+			map[Team]int{SoloTeam(0): 3, SoloTeam(1): 2, SoloTeam(2): 1, SoloTeam(3): 0},
+			map[Team]int{SoloTeam(0): 16, SoloTeam(1): 5, SoloTeam(2): -5, SoloTeam(3): -16}},
+		{"empty team ignored",
+			map[Team][]*actor.PID{TeamLawmen: {pid("a")}, TeamOutlaws: {}},
+			map[Team]int{TeamLawmen: 1, TeamOutlaws: 0},
+			map[Team]int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ratingChanges(&MatchResult{Teams: tt.teams, Scores: tt.scores}, ratings)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRatingWindow(t *testing.T) {
+	queuedAt := time.Now()
+	tests := []struct {
+		name   string
+		waited time.Duration
+		want   int
+	}{
+		{"just queued", 0, ratingWindowBase},
+		{"under a second", 900 * time.Millisecond, ratingWindowBase},
+		{"ten seconds", 10 * time.Second, ratingWindowBase + 10*ratingWindowGrowth},
+		{"right at the cap", 90 * time.Second, ratingWindowMax},
+		{"capped", 10 * time.Minute, ratingWindowMax},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			player := &PlayerStatus{QueuedAt: queuedAt}
+			if got := player.ratingWindow(queuedAt.Add(tt.waited)); got != tt.want {
+				t.Errorf("window = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// Ready check prima di ogni match
+const (
+	acceptTimeout   = 15 * time.Second
+	declineCooldown = time.Minute
+)
+
+// matchProposal is a match found by the queue, waiting for every player to
+// accept it before the Match is spawned.
+type matchProposal struct {
+	ID       int
+	Queue    queueKey
+	Groups   []*queueGroup
+	Accepted map[string]bool
+	Deadline time.Time
+}
+
+// Risposta del giocatore a "match_found"
+type MatchResponse struct {
+	PID        *actor.PID
+	ProposalID int
+	Accept     bool
+}
+
+func (p *matchProposal) players() []*PlayerStatus {
+	var players []*PlayerStatus
+	for _, group := range p.Groups {
+		players = append(players, group.players...)
+	}
+	return players
+}
+
+// proposeMatch reserves the players of groups and asks each of them to
+// accept the match within acceptTimeout.
+func (m *Matchmaking) proposeMatch(c *actor.Context, key queueKey, groups []*queueGroup) {
+	mode := key.Mode
+	m.nextProposalID++
+	proposal := &matchProposal{
+		ID:       m.nextProposalID,
+		Queue:    key,
+		Groups:   groups,
+		Accepted: make(map[string]bool),
+		Deadline: time.Now().Add(acceptTimeout),
+	}
+	m.proposals[proposal.ID] = proposal
+
+	players := proposal.players()
+	for _, player := range players {
+		player.Free = false
+		player.Proposal = proposal.ID
+	}
+	for _, player := range players {
+		notifyPlayer(c, player.PID, &MatchFoundMsg{
+			ProposalID:   proposal.ID,
+			Mode:         mode.String(),
+			Players:      len(players),
+			AcceptWithin: acceptTimeout.Seconds(),
+		})
+	}
+
+	fmt.Printf(" Match proposto #%d: %s, %d giocatori\n", proposal.ID, mode, len(players))
+}
+
+// respond records the answer of pid. The Match starts once everyone
+// accepted; a single decline cancels the proposal.
+func (m *Matchmaking) respond(c *actor.Context, pid *actor.PID, proposalID int, accept bool) {
+	proposal, ok := m.proposals[proposalID]
+	if !ok {
+		return
+	}
+	player, ok := m.players[pid.String()]
+	if !ok || player.Proposal != proposalID {
+		return
+	}
+
+	if !accept {
+		m.cancelProposal(c, proposal, map[string]bool{pid.String(): true})
+		return
+	}
+
+	proposal.Accepted[pid.String()] = true
+	players := proposal.players()
+	for _, player := range players {
+		notifyPlayer(c, player.PID, &MatchAcceptStatusMsg{
+			ProposalID: proposal.ID,
+			Accepted:   len(proposal.Accepted),
+			Players:    len(players),
+		})
+	}
+
+	if len(proposal.Accepted) == len(players) {
+		delete(m.proposals, proposal.ID)
+		m.startMatch(c, proposal.Queue, proposal.Groups)
+	}
+}
+
+// expireProposals cancels the proposals not accepted in time: whoever did
+// not answer counts as a decline.
+func (m *Matchmaking) expireProposals(c *actor.Context) {
+	now := time.Now()
+	for _, proposal := range m.proposals {
+		if now.Before(proposal.Deadline) {
+			continue
+		}
+		missing := make(map[string]bool)
+		for _, player := range proposal.players() {
+			if !proposal.Accepted[player.PID.String()] {
+				missing[player.PID.String()] = true
+			}
+		}
+		m.cancelProposal(c, proposal, missing)
+	}
+
+	for id, until := range m.cooldowns {
+		if now.After(until) {
+			delete(m.cooldowns, id)
+		}
+	}
+}
+
+// cancelProposal drops a proposal: the decliners get a queue cooldown and
+// leave the queue with their party, everyone else goes back to the front
+// of the queue keeping the original queue time.
+func (m *Matchmaking) cancelProposal(c *actor.Context, proposal *matchProposal, declined map[string]bool) {
+	delete(m.proposals, proposal.ID)
+
+	for _, group := range proposal.Groups {
+		groupDeclined, regroup := false, false
+		for _, player := range group.players {
+			if declined[player.PID.String()] {
+				groupDeclined = true
+			}
+			regroup = regroup || player.Regroup
+		}
+
+		for _, player := range group.players {
+			id := player.PID.String()
+			if !groupDeclined && regroup {
+				// Il party è cambiato nel frattempo: si rimette in coda da capo
+				delete(m.players, id)
+				notifyPlayer(c, player.PID, &MatchCancelledMsg{ProposalID: proposal.ID, Reason: "player_declined"})
+				notifyPlayer(c, player.PID, &QueueStatusMsg{State: "left", Reason: "party_changed"})
+				continue
+			}
+			if !groupDeclined {
+				player.Free = true
+				player.Proposal = 0
+				notifyPlayer(c, player.PID, &MatchCancelledMsg{ProposalID: proposal.ID, Reason: "player_declined"})
+				continue
+			}
+
+			delete(m.players, id)
+			if !declined[id] {
+				notifyPlayer(c, player.PID, &MatchCancelledMsg{ProposalID: proposal.ID, Reason: "party_member_declined"})
+				notifyPlayer(c, player.PID, &QueueStatusMsg{State: "left"})
+				continue
+			}
+
+			m.cooldowns[m.identity(player.PID)] = time.Now().Add(declineCooldown)
+			fmt.Printf("Giocatore %s ha rifiutato il match #%d\n", id, proposal.ID)
+
+			notifyPlayer(c, player.PID, &MatchCancelledMsg{ProposalID: proposal.ID, Reason: "declined"})
+			notifyPlayer(c, player.PID, &QueueStatusMsg{
+				State:     "cooldown",
+				Remaining: int(declineCooldown.Seconds()),
+			})
+		}
+	}
+
+	m.findMatches(c)
+	m.sendQueueStatus(c)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+func TestDeclineCooldownSurvivesReconnect(t *testing.T) {
+	e := newTestEngine(t)
+	mm := e.Spawn(NewMatchmaking(DefaultMatchConfig()), "matchmaking")
+	defer e.Poison(mm)
+
+	a, b := newProbe(e, "a"), newProbe(e, "b")
+	e.Send(mm, &SessionOnline{PID: a.pid, Identity: "account:alice"})
+	e.Send(mm, &SessionOnline{PID: b.pid, Identity: "account:bob"})
+
+	e.Send(mm, &QueueRequest{PID: a.pid, Mode: Duel})
+	e.Send(mm, &QueueRequest{PID: b.pid, Mode: Duel})
+	found := a.expect(t, "match_found", isMatchFound).(*MatchFoundMsg)
+
+	// a rifiuta, chiude la connessione e si ricollega con lo stesso account
+	e.Send(mm, &MatchResponse{PID: a.pid, ProposalID: found.ProposalID, Accept: false})
+	a.expect(t, "cooldown", queueState("cooldown"))
+	e.Send(mm, &QueueLeave{PID: a.pid, Disconnected: true})
+
+	again := newProbe(e, "a-again")
+	e.Send(mm, &SessionOnline{PID: again.pid, Identity: "account:alice"})
+	e.Send(mm, &QueueRequest{PID: again.pid, Mode: Duel})
+	status := again.expect(t, "cooldown", func(msg ServerMessage) bool {
+		status, ok := msg.(*QueueStatusMsg)
+		return ok && (status.State == "cooldown" || status.State == "queued")
+	}).(*QueueStatusMsg)
+	if status.State != "cooldown" {
+		t.Errorf("reconnected player %s, want cooldown", status.State)
+	}
+
+	// Un altro account non eredita il cooldown: trova subito b
+	other := newProbe(e, "carol")
+	e.Send(mm, &SessionOnline{PID: other.pid, Identity: "account:carol"})
+	e.Send(mm, &QueueRequest{PID: other.pid, Mode: Duel})
+	other.expect(t, "match_found", isMatchFound)
+}
+
+func TestCancelProposal(t *testing.T) {
+	tests := []struct {
+		name     string
+		accept   []string
+		decline  string
+		want     map[string]string // reason di "match_cancelled" per giocatore
+		cooldown string
+	}{
+		{"solo declines", nil, "s1",
+			map[string]string{"s1": "declined", "p1": "player_declined", "p2": "player_declined", "s2": "player_declined"}, "s1"},
+		{"party member declines", nil, "p1",
+			map[string]string{"p1": "declined", "p2": "party_member_declined", "s1": "player_declined", "s2": "player_declined"}, "p1"},
+		{"decline after the others accepted", []string{"p1", "p2", "s2"}, "s1",
+			map[string]string{"s1": "declined", "p1": "player_declined", "p2": "player_declined", "s2": "player_declined"}, "s1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEngine(t)
+			config := DefaultMatchConfig()
+			config.TeamSize = 2
+			mm := e.Spawn(NewMatchmaking(config), "matchmaking")
+			defer e.Poison(mm)
+
+			probes := make(map[string]*probe)
+			for _, name := range []string{"p1", "p2", "s1", "s2"} {
+				probes[name] = newProbe(e, name)
+				e.Send(mm, &SessionOnline{PID: probes[name].pid, Identity: "account:" + name})
+			}
+			party := []*actor.PID{probes["p1"].pid, probes["p2"].pid}
+			e.Send(mm, &QueueRequest{PID: party[0], Mode: TeamDeathmatch, Party: party, PartyID: 1})
+			e.Send(mm, &QueueRequest{PID: probes["s1"].pid, Mode: TeamDeathmatch})
+			e.Send(mm, &QueueRequest{PID: probes["s2"].pid, Mode: TeamDeathmatch})
+
+			var proposal int
+			for _, p := range probes {
+				proposal = p.expect(t, "match_found", isMatchFound).(*MatchFoundMsg).ProposalID
+			}
+			for _, name := range tt.accept {
+				e.Send(mm, &MatchResponse{PID: probes[name].pid, ProposalID: proposal, Accept: true})
+			}
+			e.Send(mm, &MatchResponse{PID: probes[tt.decline].pid, ProposalID: proposal, Accept: false})
+
+			for name, reason := range tt.want {
+				cancelled := probes[name].expect(t, "match_cancelled", isMatchCancelled).(*MatchCancelledMsg)
+				if cancelled.Reason != reason {
+					t.Errorf("%s: cancelled for %q, want %q", name, cancelled.Reason, reason)
+				}
+			}
+
+			// Il cooldown vale per l'account, anche da una nuova sessione
+			again := newProbe(e, tt.cooldown+"-again")
+			e.Send(mm, &SessionOnline{PID: again.pid, Identity: "account:" + tt.cooldown})
+			e.Send(mm, &QueueRequest{PID: again.pid, Mode: TeamDeathmatch})
+			status := again.expect(t, "cooldown", queueState("cooldown")).(*QueueStatusMsg)
+			if status.Remaining <= 0 || status.Remaining > int(declineCooldown.Seconds())+1 {
+				t.Errorf("cooldown of %ds, want up to %v", status.Remaining, declineCooldown)
+			}
+			for name := range tt.want {
+				if name != tt.cooldown && probes[name].got(queueState("cooldown")) {
+					t.Errorf("%s got a cooldown without declining", name)
+				}
+			}
+		})
+	}
+}
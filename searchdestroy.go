@@ -0,0 +1,468 @@
+package main
+
+import (
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// searchAndDestroy is the round based bomb mode: outlaws plant the dynamite
+// bundle, lawmen defend or defuse it.
+type searchAndDestroy struct {
+	baseMode
+
+	maxRounds  int
+	roundTime  time.Duration
+	buyTime    time.Duration
+	plantTime  time.Duration
+	defuseTime time.Duration
+
+	// Round state
+	bombPlanted   bool
+	bombPlantTime time.Time
+	bombDefused   bool
+	roundTimer    int
+
+	// The single bomb: carried by an outlaw, lying on the ground or planted
+	bombCarrier  *actor.PID
+	bombDropped  bool
+	bombPosition Position
+	bombSite     string
+
+	channel *bombChannel
+}
+
+// bombChannel is a plant or defuse in progress: the player must stay still
+// inside the site until it completes.
+type bombChannel struct {
+	pid      *actor.PID
+	defuse   bool
+	site     Zone
+	start    time.Time
+	duration time.Duration
+	position Position
+	lastStep int
+}
+
+const (
+	// Spostamento massimo tollerato durante piazzamento e disinnesco
+	channelMoveTolerance = 0.2
+	// Distanza entro cui si raccoglie la bomba a terra
+	bombPickupRadius = 1.5
+	// plant_progress/defuse_progress ogni decimo di avanzamento
+	channelProgressSteps = 10
+)
+
+func newSearchAndDestroy() *searchAndDestroy {
+	return &searchAndDestroy{
+		maxRounds:  16,
+		roundTime:  time.Minute * 2,
+		buyTime:    time.Second * 15,
+		plantTime:  time.Second * 3,
+		defuseTime: time.Second * 5,
+	}
+}
+
+func (sd *searchAndDestroy) Type() GameModeType {
+	return SearchAndDestroy
+}
+
+func (sd *searchAndDestroy) OnMatchStart(m *Match, c *actor.Context) {
+	m.after(c, 5*time.Second, "start_round")
+}
+
+func (sd *searchAndDestroy) OnRoundStart(m *Match, c *actor.Context) {
+	m.phase = PhaseBuyTime
+	sd.bombPlanted = false
+	sd.bombDefused = false
+	sd.bombSite = ""
+	sd.channel = nil
+
+	// Give money based on round result
+	if m.currentRound > 1 {
+		for pid := range m.teams {
+			roundReward := GetRoundReward(false, false, false) // Base reward
+			m.playerMoney[pid] += roundReward
+		}
+	}
+
+	m.broadcastWithTeamMoney(c, func(money map[string]int) ServerMessage {
+		return &RoundStartMsg{
+			Round:        m.currentRound,
+			Phase:        "buy_time",
+			BuyTime:      int(sd.buyTime.Seconds()),
+			TimeLimit:    int(sd.roundTime.Seconds()),
+			LawmenScore:  m.lawmenScore,
+			OutlawsScore: m.outlawsScore,
+			Money:        money,
+		}
+	})
+
+	log.Printf("Round %d iniziato - Buy Phase - Lawmen: %d, Outlaws: %d",
+		m.currentRound, m.lawmenScore, m.outlawsScore)
+
+	sd.giveBomb(m, c)
+
+	// Buy time timer
+	m.after(c, sd.buyTime, "end_buy_time")
+}
+
+// giveBomb hands the bomb to a random outlaw at round start.
+func (sd *searchAndDestroy) giveBomb(m *Match, c *actor.Context) {
+	sd.bombCarrier = nil
+	sd.bombDropped = false
+
+	outlaws := m.roster[TeamOutlaws]
+	if len(outlaws) == 0 {
+		return
+	}
+	sd.pickUpBomb(m, c, outlaws[rand.N(len(outlaws))])
+}
+
+func (sd *searchAndDestroy) pickUpBomb(m *Match, c *actor.Context, pid *actor.PID) {
+	sd.bombCarrier = pid
+	sd.bombDropped = false
+
+	m.broadcastTeam(c, TeamOutlaws, &BombPickedUpMsg{Carrier: playerID(pid)})
+}
+
+// dropBomb leaves the bomb where its carrier died, for any outlaw to pick up.
+func (sd *searchAndDestroy) dropBomb(m *Match, c *actor.Context) {
+	carrier := sd.bombCarrier
+	sd.bombCarrier = nil
+	sd.bombDropped = true
+	if state, ok := m.playerStates[carrier]; ok {
+		sd.bombPosition = state.Position
+	}
+
+	m.broadcast(c, &BombDroppedMsg{
+		X: sd.bombPosition.X,
+		Y: sd.bombPosition.Y,
+		Z: sd.bombPosition.Z,
+	})
+
+	log.Printf("Bomba caduta a terra (%s)", carrier.String())
+}
+
+func (sd *searchAndDestroy) OnTimer(m *Match, c *actor.Context, timer *matchTimer) {
+	switch timer.Name {
+	case "start_round":
+		m.startRound(c)
+	case "end_buy_time":
+		sd.endBuyTime(m, c)
+	case "round_timer":
+		sd.checkRoundTimer(m, c)
+	case "bomb_exploded":
+		if m.phase == PhaseActive && sd.bombPlanted && !sd.bombDefused {
+			sd.endRound(m, c, TeamOutlaws, "Bomb exploded")
+		}
+	}
+}
+
+// AllowAction cancels a plant or defuse when the player shoots or throws.
+func (sd *searchAndDestroy) AllowAction(m *Match, c *actor.Context, pid *actor.PID, action string) (bool, string) {
+	switch action {
+	case "shoot", "throw_dynamite":
+		if sd.channel != nil && sd.channel.pid == pid {
+			sd.cancelChannel(m, c, "shot_fired")
+		}
+	}
+	return true, ""
+}
+
+// OnTick advances the plant/defuse in progress and lets outlaws pick up the
+// dropped bomb by walking over it.
+func (sd *searchAndDestroy) OnTick(m *Match, c *actor.Context, now time.Time) {
+	if sd.channel != nil {
+		sd.updateChannel(m, c, now)
+	}
+
+	if sd.bombDropped {
+		for _, pid := range m.roster[TeamOutlaws] {
+			state, ok := m.playerStates[pid]
+			if ok && m.playersAlive[pid] && state.Position.Distance(sd.bombPosition) <= bombPickupRadius {
+				sd.pickUpBomb(m, c, pid)
+				break
+			}
+		}
+	}
+}
+
+func (sd *searchAndDestroy) OnPlayerKilled(m *Match, c *actor.Context, victim, killer *actor.PID, weapon WeaponType) {
+	if sd.channel != nil && sd.channel.pid == victim {
+		sd.cancelChannel(m, c, "killed")
+	}
+	if sd.bombCarrier == victim {
+		sd.dropBomb(m, c)
+	}
+}
+
+// OnPlayerLeft covers the buy time too, when nobody dies on the way out.
+func (sd *searchAndDestroy) OnPlayerLeft(m *Match, c *actor.Context, pid *actor.PID) {
+	if sd.channel != nil && sd.channel.pid == pid {
+		sd.cancelChannel(m, c, "left")
+	}
+	if sd.bombCarrier == pid {
+		sd.dropBomb(m, c)
+	}
+}
+
+func (sd *searchAndDestroy) OnObjectiveAction(m *Match, c *actor.Context, pid *actor.PID, msg ClientMessage) bool {
+	switch msg := msg.(type) {
+	case *BuyWeaponMsg:
+		if m.phase == PhaseBuyTime {
+			m.handleBuyWeapon(c, pid, msg)
+		}
+	case *PlantBombMsg:
+		if m.phase == PhaseActive {
+			sd.handleBombPlant(m, c, pid)
+		}
+	case *DefuseBombMsg:
+		if m.phase == PhaseActive {
+			sd.handleBombDefuse(m, c, pid)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func (sd *searchAndDestroy) CheckWinCondition(m *Match, c *actor.Context) {
+	if m.phase != PhaseActive {
+		return
+	}
+
+	lawmenAlive := m.aliveCount(TeamLawmen)
+	outlawsAlive := m.aliveCount(TeamOutlaws)
+
+	if lawmenAlive == 0 {
+		sd.endRound(m, c, TeamOutlaws, "All lawmen eliminated")
+	} else if outlawsAlive == 0 {
+		sd.endRound(m, c, TeamLawmen, "All outlaws eliminated")
+	}
+}
+
+func (sd *searchAndDestroy) WriteState(m *Match, world WorldState) {
+	world["match"]["bomb_planted"] = sd.bombPlanted
+	world["match"]["bomb_defused"] = sd.bombDefused
+	world["match"]["bomb_site"] = sd.bombSite
+
+	// Il portatore resta segreto: si vede solo la bomba a terra o piazzata
+	if sd.bombDropped || sd.bombPlanted {
+		world["bomb"] = map[string]interface{}{
+			"x": sd.bombPosition.X,
+			"y": sd.bombPosition.Y,
+			"z": sd.bombPosition.Z,
+		}
+	}
+}
+
+func (sd *searchAndDestroy) endBuyTime(m *Match, c *actor.Context) {
+	m.phase = PhaseActive
+
+	m.broadcast(c, &BuyTimeEndMsg{Phase: "active"})
+
+	// Start round timer
+	sd.roundTimer = m.after(c, sd.roundTime, "round_timer")
+}
+
+func (sd *searchAndDestroy) endRound(m *Match, c *actor.Context, winner Team, reason string) {
+	m.phase = PhaseEnd
+	m.cancelRoundTimers(c)
+	sd.channel = nil
+
+	// Award round money
+	roundReward := GetRoundReward(true, sd.bombDefused, sd.bombPlanted)
+	loseReward := GetRoundReward(false, false, false)
+
+	for pid := range m.playerMoney {
+		if m.teams[pid] == winner {
+			m.playerMoney[pid] += roundReward
+		} else {
+			m.playerMoney[pid] += loseReward
+		}
+	}
+
+	// Update score
+	if winner == TeamLawmen {
+		m.lawmenScore++
+	} else {
+		m.outlawsScore++
+	}
+
+	m.broadcastWithTeamMoney(c, func(money map[string]int) ServerMessage {
+		return &RoundEndMsg{
+			Winner:       m.getTeamName(winner),
+			Reason:       reason,
+			LawmenScore:  m.lawmenScore,
+			OutlawsScore: m.outlawsScore,
+			Money:        money,
+		}
+	})
+
+	log.Printf("Round %d terminato - Vincitore: %s (%s)",
+		m.currentRound, m.getTeamName(winner), reason)
+
+	roundsToWin := sd.maxRounds/2 + 1
+	if m.lawmenScore >= roundsToWin || m.outlawsScore >= roundsToWin {
+		m.endMatch(c)
+	} else {
+		m.currentRound++
+		m.after(c, 5*time.Second, "start_round")
+	}
+}
+
+// handleBombPlant starts planting: only the carrier, inside a bomb site.
+func (sd *searchAndDestroy) handleBombPlant(m *Match, c *actor.Context, planter *actor.PID) {
+	if m.teams[planter] != TeamOutlaws || sd.bombPlanted || sd.bombCarrier != planter {
+		return
+	}
+	sd.startChannel(m, c, planter, false)
+}
+
+// handleBombDefuse starts defusing: any lawman inside the site of the
+// planted bomb.
+func (sd *searchAndDestroy) handleBombDefuse(m *Match, c *actor.Context, defuser *actor.PID) {
+	if m.teams[defuser] != TeamLawmen || !sd.bombPlanted || sd.bombDefused {
+		return
+	}
+	sd.startChannel(m, c, defuser, true)
+}
+
+func (sd *searchAndDestroy) startChannel(m *Match, c *actor.Context, pid *actor.PID, defuse bool) {
+	if sd.channel != nil || !m.playersAlive[pid] {
+		return
+	}
+	state, ok := m.playerStates[pid]
+	if !ok {
+		return
+	}
+
+	site, inside := ZoneAt(m.gameMap.BombSites, state.Position)
+	if defuse && site.Name != sd.bombSite {
+		inside = false
+	}
+	ch := &bombChannel{pid: pid, defuse: defuse}
+	if !inside {
+		m.sendToPlayer(c, pid, &BombChannelMsg{
+			Defuse: defuse,
+			State:  "rejected",
+			Reason: "not_in_bomb_site",
+		})
+		return
+	}
+
+	ch.site = site
+	ch.start = time.Now()
+	ch.position = state.Position
+	ch.duration = sd.plantTime
+	if defuse {
+		ch.duration = sd.defuseTime
+	}
+	sd.channel = ch
+
+	m.broadcast(c, &BombChannelMsg{
+		Defuse:   defuse,
+		State:    "started",
+		Player:   playerID(pid),
+		Site:     site.Name,
+		Duration: ch.duration.Seconds(),
+	})
+}
+
+// updateChannel cancels the channel if the player left the site or moved,
+// otherwise reports the progress and completes it when time is up.
+func (sd *searchAndDestroy) updateChannel(m *Match, c *actor.Context, now time.Time) {
+	ch := sd.channel
+	state, ok := m.playerStates[ch.pid]
+	if !ok || !m.playersAlive[ch.pid] {
+		sd.cancelChannel(m, c, "killed")
+		return
+	}
+	if !ch.site.Contains(state.Position) {
+		sd.cancelChannel(m, c, "left_site")
+		return
+	}
+	if state.Position.Distance(ch.position) > channelMoveTolerance {
+		sd.cancelChannel(m, c, "moved")
+		return
+	}
+
+	progress := min(1, now.Sub(ch.start).Seconds()/ch.duration.Seconds())
+	if progress >= 1 {
+		sd.channel = nil
+		if ch.defuse {
+			sd.defuseBomb(m, c, ch.pid)
+		} else {
+			sd.plantBomb(m, c, ch.pid, ch.site, state.Position)
+		}
+		return
+	}
+
+	if step := int(progress * channelProgressSteps); step != ch.lastStep {
+		ch.lastStep = step
+		m.broadcast(c, &BombChannelMsg{
+			Defuse:   ch.defuse,
+			State:    "progress",
+			Player:   playerID(ch.pid),
+			Site:     ch.site.Name,
+			Progress: progress,
+		})
+	}
+}
+
+func (sd *searchAndDestroy) cancelChannel(m *Match, c *actor.Context, reason string) {
+	ch := sd.channel
+	sd.channel = nil
+
+	m.broadcast(c, &BombChannelMsg{
+		Defuse: ch.defuse,
+		State:  "cancelled",
+		Player: playerID(ch.pid),
+		Site:   ch.site.Name,
+		Reason: reason,
+	})
+}
+
+func (sd *searchAndDestroy) plantBomb(m *Match, c *actor.Context, planter *actor.PID, site Zone, pos Position) {
+	sd.bombPlanted = true
+	sd.bombPlantTime = time.Now()
+	// Da qui decide solo la miccia
+	m.cancelTimer(c, sd.roundTimer)
+	sd.bombCarrier = nil
+	sd.bombSite = site.Name
+	sd.bombPosition = pos
+
+	m.broadcast(c, &BombPlantedMsg{
+		PlantedBy: m.getPlayerName(planter),
+		Site:      site.Name,
+	})
+
+	log.Printf("Bomba piazzata da %s nel sito %s", planter.String(), site.Name)
+
+	m.after(c, 45*time.Second, "bomb_exploded")
+}
+
+func (sd *searchAndDestroy) defuseBomb(m *Match, c *actor.Context, defuser *actor.PID) {
+	sd.bombDefused = true
+
+	m.broadcast(c, &BombDefusedMsg{DefusedBy: m.getPlayerName(defuser)})
+
+	log.Printf("Bomba disinnescata da %s", defuser.String())
+
+	sd.endRound(m, c, TeamLawmen, "Bomb defused")
+}
+
+func (sd *searchAndDestroy) checkRoundTimer(m *Match, c *actor.Context) {
+	if m.phase != PhaseActive {
+		return
+	}
+
+	if sd.bombPlanted && !sd.bombDefused {
+		sd.endRound(m, c, TeamOutlaws, "Bomb exploded")
+	} else {
+		sd.endRound(m, c, TeamLawmen, "Time expired")
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 
@@ -8,9 +9,14 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+// Porta del trasporto UDP opzionale
+const udpPort = 4001
+
 type Server struct {
 	address        string
 	matchmakingPID *actor.PID
+	partiesPID     *actor.PID
+	udpPID         *actor.PID
 }
 
 func NewServer(addr string) actor.Producer {
@@ -20,15 +26,20 @@ func NewServer(addr string) actor.Producer {
 }
 
 func (s *Server) Receive(c *actor.Context) {
-	switch msg := c.Message().(type) {
+	switch c.Message().(type) {
 	case actor.Started:
 
-		s.startHTTP(c) //SERVER START
-
-		s.matchmakingPID = c.SpawnChild(NewMatchmaking(), "matchmaking") //SPAWN MATCHMAKING
+		s.matchmakingPID = c.SpawnChild(NewMatchmaking(DefaultMatchConfig()), "matchmaking") //SPAWN MATCHMAKING
+		s.partiesPID = c.SpawnChild(NewParties(s.matchmakingPID), "parties")                 //SPAWN PARTY
+		if conn, err := listenUDP(fmt.Sprintf(":%d", udpPort)); err != nil {
+			// Senza gateway le sessioni non offrono l'UDP ai client
+			log.Println("UDP non disponibile, solo websocket:", err)
+		} else {
+			s.udpPID = c.SpawnChild(NewUDPGateway(conn), "udp") //SPAWN UDP
+		}
 
-	case *actor.PID:
-		c.Send(s.matchmakingPID, msg)
+		// Le sessioni leggono i PID qui sopra: l'HTTP parte per ultimo
+		s.startHTTP(c) //SERVER START
 	}
 }
 func (s *Server) startHTTP(c *actor.Context) {
@@ -42,8 +53,13 @@ func (s *Server) startHTTP(c *actor.Context) {
 			}
 			// Spawn PlayerSession
 			sessionPID := c.SpawnChild(NewSession(conn), "session")
-			// Comunica al session actor anche il PID del matchmaking
-			c.Send(sessionPID, s.matchmakingPID)
+			// Comunica al session actor i PID di matchmaking, party e UDP
+			c.Send(sessionPID, &Lobby{
+				Matchmaking: s.matchmakingPID,
+				Parties:     s.partiesPID,
+				UDP:         s.udpPID,
+				UDPPort:     udpPort,
+			})
 		})
 		log.Println("Server WS in ascolto su :4000/ws")
 		http.ListenAndServe(":4000", nil)
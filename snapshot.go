@@ -0,0 +1,93 @@
+package main
+
+import "time"
+
+// Numero di snapshot inviati conservati per player: un ack più vecchio di
+// questa finestra forza uno snapshot completo.
+const snapshotHistorySize = 32
+
+// WorldState is the replicated state of a Match: entity id -> field -> value.
+// Field values must be comparable scalars (numbers, strings, bools).
+type WorldState map[string]map[string]interface{}
+
+type sentSnapshot struct {
+	seq    int
+	state  WorldState
+	sentAt time.Time
+}
+
+// snapshotHistory tracks what a single client has been sent and acknowledged.
+type snapshotHistory struct {
+	entries [snapshotHistorySize]sentSnapshot
+	lastAck int
+}
+
+func (h *snapshotHistory) record(seq int, state WorldState, now time.Time) {
+	h.entries[seq%snapshotHistorySize] = sentSnapshot{seq: seq, state: state, sentAt: now}
+}
+
+func (h *snapshotHistory) lookup(seq int) (sentSnapshot, bool) {
+	entry := h.entries[seq%snapshotHistorySize]
+	if seq <= 0 || entry.seq != seq {
+		return sentSnapshot{}, false
+	}
+	return entry, true
+}
+
+// ack registers the client acknowledgement of seq. Out of order acks for
+// older snapshots are ignored.
+func (h *snapshotHistory) ack(seq int) bool {
+	if seq <= h.lastAck {
+		return false
+	}
+	if _, ok := h.lookup(seq); !ok {
+		return false
+	}
+	h.lastAck = seq
+	return true
+}
+
+// baseline returns the acknowledged state deltas are computed against.
+func (h *snapshotHistory) baseline(seq int) (sentSnapshot, bool) {
+	if h.lastAck == 0 || seq-h.lastAck >= snapshotHistorySize {
+		return sentSnapshot{}, false
+	}
+	return h.lookup(h.lastAck)
+}
+
+// Diff returns the entities and fields of s that differ from base, the ids
+// of entities that no longer exist and, for the entities still there, the
+// fields they lost (e.g. "bomb" once the carrier drops it).
+func (s WorldState) Diff(base WorldState) (changed WorldState, removed []string, removedFields map[string][]string) {
+	changed = make(WorldState)
+	for id, fields := range s {
+		baseFields, ok := base[id]
+		if !ok {
+			changed[id] = fields
+			continue
+		}
+		delta := make(map[string]interface{})
+		for k, v := range fields {
+			if old, ok := baseFields[k]; !ok || old != v {
+				delta[k] = v
+			}
+		}
+		if len(delta) > 0 {
+			changed[id] = delta
+		}
+		for k := range baseFields {
+			if _, ok := fields[k]; !ok {
+				if removedFields == nil {
+					removedFields = make(map[string][]string)
+				}
+				removedFields[id] = append(removedFields[id], k)
+			}
+		}
+	}
+	for id := range base {
+		if _, ok := s[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	return changed, removed, removedFields
+}
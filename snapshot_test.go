@@ -0,0 +1,120 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSnapshotHistoryBaseline(t *testing.T) {
+	tests := []struct {
+		name     string
+		sent     int   // snapshot 1..sent registrati
+		acks     []int // ack del client, in ordine di arrivo
+		accepted []bool
+		next     int // seq dello snapshot da costruire
+		wantBase int // 0: snapshot completo
+	}{
+		{"no ack yet", 3, nil, nil, 4, 0},
+		{"latest ack", 3, []int{3}, []bool{true}, 4, 3},
+		{"acks in order", 5, []int{2, 4}, []bool{true, true}, 6, 4},
+		{"out of order ack ignored", 5, []int{4, 2}, []bool{true, false}, 6, 4},
+		{"duplicate ack ignored", 5, []int{4, 4}, []bool{true, false}, 6, 4},
+		{"ack of unsent snapshot ignored", 5, []int{9}, []bool{false}, 6, 0},
+		{"stale ack forces full snapshot", 5, []int{5}, []bool{true}, 5 + snapshotHistorySize, 0},
+		{"oldest ack still in window", 10, []int{10}, []bool{true}, 9 + snapshotHistorySize, 10},
+		{"overwritten snapshot cannot be acked", 40, []int{1}, []bool{false}, 41, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var h snapshotHistory
+			now := time.Now()
+			for seq := 1; seq <= tt.sent; seq++ {
+				h.record(seq, WorldState{"p": {"seq": seq}}, now)
+			}
+			for i, seq := range tt.acks {
+				if got := h.ack(seq); got != tt.accepted[i] {
+					t.Errorf("ack(%d) = %v, want %v", seq, got, tt.accepted[i])
+				}
+			}
+
+			base, ok := h.baseline(tt.next)
+			if tt.wantBase == 0 {
+				if ok {
+					t.Fatalf("baseline = %d, want a full snapshot", base.seq)
+				}
+				return
+			}
+			if !ok || base.seq != tt.wantBase {
+				t.Fatalf("baseline = %d (%v), want %d", base.seq, ok, tt.wantBase)
+			}
+			if base.state["p"]["seq"] != tt.wantBase {
+				t.Errorf("baseline state is of snapshot %v", base.state["p"]["seq"])
+			}
+		})
+	}
+}
+
+func TestWorldStateDiff(t *testing.T) {
+	base := WorldState{
+		"p1": {"x": 1.0, "hp": 100, "stance": "stand"},
+		"p2": {"x": 5.0, "hp": 100},
+	}
+
+	tests := []struct {
+		name              string
+		state             WorldState
+		wantChanged       WorldState
+		wantRemoved       []string
+		wantRemovedFields map[string][]string
+	}{
+		{"unchanged", WorldState{
+			"p1": {"x": 1.0, "hp": 100, "stance": "stand"},
+			"p2": {"x": 5.0, "hp": 100},
+		}, WorldState{}, nil, nil},
+		{"changed field", WorldState{
+			"p1": {"x": 1.5, "hp": 100, "stance": "crouch"},
+			"p2": {"x": 5.0, "hp": 100},
+		}, WorldState{"p1": {"x": 1.5, "stance": "crouch"}}, nil, nil},
+		{"new field", WorldState{
+			"p1": {"x": 1.0, "hp": 100, "stance": "stand"},
+			"p2": {"x": 5.0, "hp": 100, "bomb": true},
+		}, WorldState{"p2": {"bomb": true}}, nil, nil},
+		{"removed field", WorldState{
+			"p1": {"x": 1.0, "hp": 100},
+			"p2": {"x": 5.0},
+		}, WorldState{}, nil, map[string][]string{"p1": {"stance"}, "p2": {"hp"}}},
+		{"removed and changed fields", WorldState{
+			"p1": {"x": 2.0},
+			"p2": {"x": 5.0, "hp": 100},
+		}, WorldState{"p1": {"x": 2.0}}, nil, map[string][]string{"p1": {"hp", "stance"}}},
+		{"removed entity", WorldState{
+			"p1": {"x": 1.0, "hp": 100, "stance": "stand"},
+		}, WorldState{}, []string{"p2"}, nil},
+		{"new entity", WorldState{
+			"p1": {"x": 1.0, "hp": 100, "stance": "stand"},
+			"p2": {"x": 5.0, "hp": 100},
+			"d1": {"x": 3.0},
+		}, WorldState{"d1": {"x": 3.0}}, nil, nil},
+		{"against empty base", nil, WorldState{}, []string{"p1", "p2"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed, removed, removedFields := tt.state.Diff(base)
+			sort.Strings(removed)
+			for _, keys := range removedFields {
+				sort.Strings(keys)
+			}
+			if !reflect.DeepEqual(changed, tt.wantChanged) {
+				t.Errorf("changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if !reflect.DeepEqual(removed, tt.wantRemoved) {
+				t.Errorf("removed = %v, want %v", removed, tt.wantRemoved)
+			}
+			if !reflect.DeepEqual(removedFields, tt.wantRemovedFields) {
+				t.Errorf("removed fields = %v, want %v", removedFields, tt.wantRemovedFields)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// spawnPointsFor returns the spawn points usable by team: solo teams share
+// the free-for-all ones.
+func (m *Match) spawnPointsFor(team Team) []SpawnPoint {
+	if team.IsSolo() {
+		return m.gameMap.FreeForAllSpawns
+	}
+	return m.gameMap.SpawnPoints[team]
+}
+
+// spawnTeam places every player of team where the mode wants it, otherwise
+// on the team spawn points in roster order. Solo teams start from their own
+// point so nobody spawns stacked.
+func (m *Match) spawnTeam(c *actor.Context, team Team) {
+	points := m.spawnPointsFor(team)
+	offset := 0
+	if team.IsSolo() {
+		offset = int(team - firstSoloTeam)
+	}
+	for i, pid := range m.roster[team] {
+		if point, ok := m.mode.SpawnPoint(m, team, i); ok {
+			m.spawnPlayer(c, pid, point)
+		} else if len(points) > 0 {
+			m.spawnPlayer(c, pid, points[(offset+i)%len(points)])
+		}
+	}
+}
+
+// pickSpawnPoint chooses the spawn point of team farthest from the closest
+// living enemy.
+func (m *Match) pickSpawnPoint(team Team) (SpawnPoint, bool) {
+	points := m.spawnPointsFor(team)
+	if len(points) == 0 {
+		return SpawnPoint{}, false
+	}
+
+	best, bestDist := points[0], -1.0
+	for _, point := range points {
+		closest := math.Inf(1)
+		for pid, state := range m.playerStates {
+			if m.teams[pid] == team || !m.playersAlive[pid] {
+				continue
+			}
+			closest = math.Min(closest, point.Position.Distance(state.Position))
+		}
+		if closest > bestDist {
+			best, bestDist = point, closest
+		}
+	}
+	return best, true
+}
+
+// respawnPlayer brings a dead player back on the best spawn point of its
+// team, with full health. It reports whether the player was respawned.
+func (m *Match) respawnPlayer(c *actor.Context, pid *actor.PID) bool {
+	if m.phase != PhaseActive || m.playersAlive[pid] {
+		return false
+	}
+	if _, ok := m.teams[pid]; !ok {
+		return false // uscito dal match prima del respawn
+	}
+
+	point, ok := m.pickSpawnPoint(m.teams[pid])
+	if !ok {
+		return false
+	}
+
+	m.playersAlive[pid] = true
+	m.playerHealth[pid] = 100
+	m.spawnPlayer(c, pid, point)
+	return true
+}
+
+// spawnPlayer teleports pid to point. Movement validation restarts from the
+// spawn and the position history is dropped so shots are never rewound
+// across the teleport.
+func (m *Match) spawnPlayer(c *actor.Context, pid *actor.PID, point SpawnPoint) {
+	state := &PlayerState{
+		Position:   point.Position,
+		Yaw:        point.Yaw,
+		LastUpdate: time.Now(),
+	}
+	if old, ok := m.playerStates[pid]; ok {
+		state.Seq = old.Seq
+	}
+	m.playerStates[pid] = state
+	delete(m.pendingMoves, pid)
+	delete(m.positionHistory, pid)
+
+	m.sendToPlayer(c, pid, &SpawnMsg{
+		X:   point.Position.X,
+		Y:   point.Position.Y,
+		Z:   point.Position.Z,
+		Yaw: point.Yaw,
+	})
+}
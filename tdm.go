@@ -0,0 +1,160 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// teamDeathmatch is a single continuous round: players respawn, every kill
+// scores a point for the team, first to the kill limit or best score when
+// time runs out wins. No economy and no bomb.
+type teamDeathmatch struct {
+	baseMode
+
+	killLimit    int
+	timeLimit    time.Duration
+	respawnDelay time.Duration
+
+	loadouts map[*actor.PID]WeaponType
+}
+
+func newTeamDeathmatch(config MatchConfig) *teamDeathmatch {
+	return &teamDeathmatch{
+		killLimit:    config.KillLimit,
+		timeLimit:    config.TDMTimeLimit,
+		respawnDelay: config.RespawnDelay,
+		loadouts:     make(map[*actor.PID]WeaponType),
+	}
+}
+
+func (td *teamDeathmatch) Type() GameModeType {
+	return TeamDeathmatch
+}
+
+func (td *teamDeathmatch) OnMatchStart(m *Match, c *actor.Context) {
+	m.after(c, 5*time.Second, "start_round")
+}
+
+// OnRoundStart starts the only round: no buy phase, players spawn with
+// their selected loadout.
+func (td *teamDeathmatch) OnRoundStart(m *Match, c *actor.Context) {
+	m.phase = PhaseActive
+
+	for pid := range m.teams {
+		td.equipLoadout(m, pid)
+	}
+
+	m.broadcast(c, &RoundStartMsg{
+		Round:        m.currentRound,
+		Phase:        "active",
+		TimeLimit:    int(td.timeLimit.Seconds()),
+		KillLimit:    td.killLimit,
+		LawmenScore:  m.lawmenScore,
+		OutlawsScore: m.outlawsScore,
+	})
+
+	log.Printf("Team Deathmatch iniziato - limite %d uccisioni", td.killLimit)
+
+	m.after(c, td.timeLimit, "time_limit")
+}
+
+func (td *teamDeathmatch) OnTimer(m *Match, c *actor.Context, timer *matchTimer) {
+	switch timer.Name {
+	case "start_round":
+		m.startRound(c)
+	case "time_limit":
+		if m.phase == PhaseActive {
+			m.endMatch(c)
+		}
+	case "respawn":
+		td.respawn(m, c, timer.PID)
+	}
+}
+
+func (td *teamDeathmatch) AllowAction(m *Match, c *actor.Context, pid *actor.PID, action string) (bool, string) {
+	switch action {
+	case "buy_weapon", "plant_bomb", "defuse_bomb":
+		return false, "not_available_in_mode"
+	}
+	return true, ""
+}
+
+func (td *teamDeathmatch) OnObjectiveAction(m *Match, c *actor.Context, pid *actor.PID, msg ClientMessage) bool {
+	loadout, ok := msg.(*SelectLoadoutMsg)
+	if !ok {
+		return false
+	}
+	td.handleSelectLoadout(m, c, pid, loadout)
+	return true
+}
+
+// OnPlayerKilled scores the kill for the killer's team and schedules the
+// victim's respawn.
+func (td *teamDeathmatch) OnPlayerKilled(m *Match, c *actor.Context, victim, killer *actor.PID, weapon WeaponType) {
+	if killer != nil && killer != victim && m.teams[killer] != m.teams[victim] {
+		if m.teams[killer] == TeamLawmen {
+			m.lawmenScore++
+		} else {
+			m.outlawsScore++
+		}
+	}
+
+	m.broadcast(c, &ScoreUpdateMsg{
+		LawmenScore:  m.lawmenScore,
+		OutlawsScore: m.outlawsScore,
+	})
+
+	m.sendToPlayer(c, victim, &RespawnInMsg{Seconds: td.respawnDelay.Seconds()})
+
+	m.afterFor(c, td.respawnDelay, "respawn", victim)
+}
+
+func (td *teamDeathmatch) CheckWinCondition(m *Match, c *actor.Context) {
+	if m.phase != PhaseActive {
+		return
+	}
+	if m.lawmenScore >= td.killLimit || m.outlawsScore >= td.killLimit {
+		m.endMatch(c)
+	}
+}
+
+func (td *teamDeathmatch) WriteState(m *Match, world WorldState) {
+	world["match"]["kill_limit"] = td.killLimit
+}
+
+func (td *teamDeathmatch) respawn(m *Match, c *actor.Context, pid *actor.PID) {
+	if m.respawnPlayer(c, pid) {
+		td.equipLoadout(m, pid)
+	}
+}
+
+// handleSelectLoadout replaces the buy menu: the chosen primary is handed
+// out for free at the next spawn.
+func (td *teamDeathmatch) OnPlayerLeft(m *Match, c *actor.Context, pid *actor.PID) {
+	delete(td.loadouts, pid)
+}
+
+func (td *teamDeathmatch) handleSelectLoadout(m *Match, c *actor.Context, pid *actor.PID, msg *SelectLoadoutMsg) {
+	weaponType := *msg.WeaponType
+	if weaponType == WeaponDynamite {
+		m.sendToPlayer(c, pid, &LoadoutFailedMsg{Reason: "invalid_weapon"})
+		return
+	}
+
+	td.loadouts[pid] = weaponType
+	m.sendToPlayer(c, pid, &LoadoutSelectedMsg{WeaponType: weaponType})
+}
+
+func (td *teamDeathmatch) equipLoadout(m *Match, pid *actor.PID) {
+	weapons := NewPlayerWeapons()
+	if loadout, ok := td.loadouts[pid]; ok && loadout != WeaponRevolver {
+		weapons.Primary = loadout
+		weapons.PrimaryAmmo = WeaponStats[loadout].AmmoCapacity
+		weapons.Current = loadout
+	}
+	weapons.Dynamite = spawnDynamite
+	weapons.Money = m.playerMoney[pid]
+	m.playerWeapons[pid] = weapons
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+type queuedMove struct {
+	input      MoveInput
+	receivedAt time.Time
+}
+
+// Frequenze di simulazione supportate, in tick al secondo
+var tickRates = []int{20, 30, 60}
+
+const defaultTickRate = 30
+
+// clampTickRate returns the supported tick rate closest to rate, or
+// defaultTickRate when rate is not set.
+func clampTickRate(rate int) int {
+	if rate <= 0 {
+		return defaultTickRate
+	}
+	best := tickRates[0]
+	for _, supported := range tickRates {
+		if abs(supported-rate) < abs(best-rate) {
+			best = supported
+		}
+	}
+	return best
+}
+
+func (m *Match) tickInterval() time.Duration {
+	return time.Second / time.Duration(m.config.TickRate)
+}
+
+func (m *Match) startTicker(c *actor.Context) {
+	ticker := c.Engine().SendRepeat(c.PID(), "tick", m.tickInterval())
+	m.ticker = &ticker
+}
+
+func (m *Match) stopTicker() {
+	if m.ticker != nil {
+		m.ticker.Stop()
+		m.ticker = nil
+	}
+}
+
+// onTick advances the simulation by one step: queued inputs are applied in
+// arrival order, then every player receives a single snapshot of the world.
+func (m *Match) onTick(c *actor.Context) {
+	m.tick++
+
+	for pid, moves := range m.pendingMoves {
+		m.applyMoves(pid, moves)
+		delete(m.pendingMoves, pid)
+	}
+	now := time.Now()
+	m.recordPositions(now)
+
+	m.runTimers(c, now)
+
+	if m.phase == PhaseActive {
+		m.updateProjectiles(c, now, m.tickInterval().Seconds())
+	}
+	// A detonation may have ended the round
+	if m.phase == PhaseActive {
+		m.updateExplosions(c, now)
+	}
+
+	if m.phase == PhaseActive {
+		m.mode.OnTick(m, c, now)
+	}
+
+	world := m.buildWorldState()
+	for pid := range m.teams {
+		m.sendSnapshot(c, pid, world)
+	}
+
+	clear(m.moveCorrected)
+}
+
+func (m *Match) applyMoves(pid *actor.PID, moves []queuedMove) {
+	if !m.playersAlive[pid] {
+		return
+	}
+
+	state, exists := m.playerStates[pid]
+	if !exists {
+		state = &PlayerState{}
+		m.playerStates[pid] = state
+	}
+
+	for _, move := range moves {
+		if _, corrected := state.ApplyMove(move.input, m.gameMap.Bounds, move.receivedAt); corrected {
+			m.moveCorrected[pid] = true
+		}
+	}
+}
+
+// buildWorldState captures the replicated state for the current tick. The
+// result is kept in the snapshot history and must not be modified afterwards.
+func (m *Match) buildWorldState() WorldState {
+	world := make(WorldState, len(m.teams)+1)
+
+	world["match"] = map[string]interface{}{
+		"phase":         m.phase.String(),
+		"round":         m.currentRound,
+		"lawmen_score":  m.lawmenScore,
+		"outlaws_score": m.outlawsScore,
+	}
+
+	for pid, team := range m.teams {
+		weapons := m.playerWeapons[pid]
+		player := map[string]interface{}{
+			"team":     m.getTeamName(team),
+			"alive":    m.playersAlive[pid],
+			"health":   m.playerHealth[pid],
+			"weapon":   int(weapons.Current),
+			"ammo":     weapons.GetCurrentAmmo(),
+			"dynamite": weapons.Dynamite,
+		}
+		if state, ok := m.playerStates[pid]; ok {
+			for k, v := range state.ToMap() {
+				player[k] = v
+			}
+		}
+		world[playerID(pid)] = player
+	}
+
+	for _, p := range m.projectiles {
+		world[p.entityID()] = map[string]interface{}{
+			"owner": playerID(p.Owner),
+			"x":     p.Position.X,
+			"y":     p.Position.Y,
+			"z":     p.Position.Z,
+		}
+	}
+
+	m.mode.WriteState(m, world)
+
+	return world
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// matchTimer is a delay scheduled with Match.after. Timers live inside the
+// Match actor and are fired by the tick, so the game mode only ever sees
+// them from Receive, at most one tick late. PID is set for per-player
+// timers (e.g. respawns).
+type matchTimer struct {
+	ID       int
+	Name     string
+	PID      *actor.PID
+	Deadline time.Time
+
+	// Round generation the timer belongs to, see cancelRoundTimers
+	generation int
+}
+
+func (t *matchTimer) remaining(now time.Time) time.Duration {
+	return max(0, t.Deadline.Sub(now))
+}
+
+// Timer che il client mostra a schermo: gli altri (es. il "draw" del duello)
+// devono restare segreti
+var syncedTimers = map[string]bool{
+	"start_round":   true,
+	"end_buy_time":  true,
+	"round_timer":   true,
+	"bomb_exploded": true,
+	"time_limit":    true,
+	"respawn":       true,
+}
+
+// Ogni quanto i client riallineano i loro conti alla rovescia
+const timerSyncInterval = 5 * time.Second
+
+// after schedules a matchTimer named name for the game mode once d elapsed
+// and returns its id.
+func (m *Match) after(c *actor.Context, d time.Duration, name string) int {
+	return m.afterFor(c, d, name, nil)
+}
+
+func (m *Match) afterFor(c *actor.Context, d time.Duration, name string, pid *actor.PID) int {
+	m.nextTimerID++
+	timer := &matchTimer{
+		ID:         m.nextTimerID,
+		Name:       name,
+		PID:        pid,
+		Deadline:   time.Now().Add(d),
+		generation: m.timerGeneration,
+	}
+	m.timers[timer.ID] = timer
+
+	if syncedTimers[name] {
+		m.syncTimers(c)
+	}
+	return timer.ID
+}
+
+// cancelTimer drops a single pending timer.
+func (m *Match) cancelTimer(c *actor.Context, id int) {
+	timer, ok := m.timers[id]
+	if !ok {
+		return
+	}
+	delete(m.timers, id)
+
+	if syncedTimers[timer.Name] {
+		m.syncTimers(c)
+	}
+}
+
+// cancelRoundTimers invalidates every pending timer at the end of a round:
+// timers scheduled afterwards belong to the next generation, the stale ones
+// are rejected by runTimers instead of firing into the next round.
+func (m *Match) cancelRoundTimers(c *actor.Context) {
+	m.timerGeneration++
+	m.syncTimers(c)
+}
+
+func (m *Match) timerActive(timer *matchTimer) bool {
+	return timer.generation == m.timerGeneration
+}
+
+// runTimers fires the expired timers in deadline order, rejecting the stale
+// ones and those cancelled by a timer fired earlier in the same tick.
+func (m *Match) runTimers(c *actor.Context, now time.Time) {
+	var due []*matchTimer
+	for _, timer := range m.timers {
+		if !timer.Deadline.After(now) {
+			due = append(due, timer)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].Deadline.Equal(due[j].Deadline) {
+			return due[i].Deadline.Before(due[j].Deadline)
+		}
+		return due[i].ID < due[j].ID
+	})
+
+	for _, timer := range due {
+		if _, ok := m.timers[timer.ID]; !ok {
+			continue
+		}
+		delete(m.timers, timer.ID)
+
+		if !m.timerActive(timer) {
+			log.Printf("Timer %s (#%d) scaduto, ignorato", timer.Name, timer.ID)
+			continue
+		}
+		m.mode.OnTimer(m, c, timer)
+	}
+
+	if now.Sub(m.lastTimerSync) >= timerSyncInterval {
+		m.syncTimers(c)
+	}
+}
+
+// syncTimers sends every player the visible timers that concern it, with
+// the exact remaining time.
+func (m *Match) syncTimers(c *actor.Context) {
+	now := time.Now()
+	m.lastTimerSync = now
+
+	for pid := range m.teams {
+		timers := []TimerInfo{}
+		for _, timer := range m.timers {
+			if !syncedTimers[timer.Name] || !m.timerActive(timer) {
+				continue
+			}
+			if timer.PID != nil && timer.PID != pid {
+				continue
+			}
+			timers = append(timers, TimerInfo{
+				ID:          timer.ID,
+				Timer:       timer.Name,
+				RemainingMs: timer.remaining(now).Milliseconds(),
+			})
+		}
+		sort.Slice(timers, func(i, j int) bool {
+			return timers[i].ID < timers[j].ID
+		})
+
+		m.sendToPlayer(c, pid, &TimerSyncMsg{Round: m.currentRound, Timers: timers})
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// timerRecorder is a game mode that records the timers it receives. A
+// "round_end" timer ends the round like the real modes do.
+type timerRecorder struct {
+	baseMode
+	fired []string
+}
+
+func (*timerRecorder) Type() GameModeType { return Duel }
+
+func (r *timerRecorder) OnTimer(m *Match, c *actor.Context, timer *matchTimer) {
+	r.fired = append(r.fired, timer.Name)
+	if timer.Name == "round_end" {
+		m.cancelRoundTimers(c)
+	}
+}
+
+type timerStep struct {
+	op   string // after, cancel, cancel_round, run
+	name string
+	at   time.Duration // ritardo per after, istante per run
+	id   int           // per cancel
+}
+
+func TestMatchTimers(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []timerStep
+		want  []string
+	}{
+		{"deadline order", []timerStep{
+			{op: "after", name: "b", at: 2 * time.Second},
+			{op: "after", name: "a", at: time.Second},
+			{op: "run", at: 3 * time.Second},
+		}, []string{"a", "b"}},
+		{"same deadline in schedule order", []timerStep{
+			{op: "after", name: "a", at: time.Second},
+			{op: "after", name: "b", at: time.Second},
+			{op: "run", at: 2 * time.Second},
+		}, []string{"a", "b"}},
+		{"not due yet", []timerStep{
+			{op: "after", name: "a", at: 2 * time.Second},
+			{op: "run", at: time.Second},
+		}, nil},
+		{"fires once", []timerStep{
+			{op: "after", name: "a", at: time.Second},
+			{op: "run", at: 2 * time.Second},
+			{op: "run", at: 3 * time.Second},
+		}, []string{"a"}},
+		{"single timer cancelled", []timerStep{
+			{op: "after", name: "a", at: time.Second},
+			{op: "after", name: "b", at: time.Second},
+			{op: "cancel", id: 1},
+			{op: "run", at: 2 * time.Second},
+		}, []string{"b"}},
+		{"stale after the round ended", []timerStep{
+			{op: "after", name: "a", at: time.Second},
+			{op: "cancel_round"},
+			{op: "run", at: 2 * time.Second},
+		}, nil},
+		{"next round timers fire", []timerStep{
+			{op: "after", name: "a", at: time.Second},
+			{op: "cancel_round"},
+			{op: "after", name: "b", at: time.Second},
+			{op: "run", at: 2 * time.Second},
+		}, []string{"b"}},
+		{"cancelled by an earlier timer of the same tick", []timerStep{
+			{op: "after", name: "round_end", at: time.Second},
+			{op: "after", name: "respawn", at: 2 * time.Second},
+			{op: "after", name: "start_round", at: 2 * time.Second},
+			{op: "run", at: 3 * time.Second},
+		}, []string{"round_end"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Senza giocatori i timer non mandano nulla: basta un Match nudo
+			recorder := &timerRecorder{}
+			m := &Match{mode: recorder, timers: make(map[int]*matchTimer)}
+			start := time.Now()
+
+			for _, step := range tt.steps {
+				switch step.op {
+				case "after":
+					m.after(nil, step.at, step.name)
+				case "cancel":
+					m.cancelTimer(nil, step.id)
+				case "cancel_round":
+					m.cancelRoundTimers(nil)
+				case "run":
+					m.runTimers(nil, start.Add(step.at))
+				}
+			}
+			if !reflect.DeepEqual(recorder.fired, tt.want) {
+				t.Errorf("fired %v, want %v", recorder.fired, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/rand"
+	"log"
+	"net"
+
+	"github.com/anthdm/hollywood/actor"
+)
+
+// Trasporto UDP opzionale per i messaggi ad alta frequenza: mosse e colpi
+// dal client, snapshot dal server. Tutto il resto resta sul websocket.
+const (
+	udpTokenSize = 16
+
+	// Oltre questa dimensione lo snapshot passa dal websocket, per non
+	// frammentare i pacchetti IP
+	maxDatagramSize = 1200
+)
+
+// Azioni del client accettate anche via UDP
+var udpActions = map[string]bool{
+	"udp_bind":     true,
+	"move":         true,
+	"shoot":        true,
+	"snapshot_ack": true,
+}
+
+// UDPGateway owns the UDP socket. A datagram is the session token followed
+// by a message in the session codec: the gateway hands it to the session
+// the token belongs to, which binds the sender address as its endpoint.
+type UDPGateway struct {
+	pid      *actor.PID
+	conn     *net.UDPConn
+	sessions map[string]*actor.PID // token -> session
+}
+
+// NewUDPGateway serves conn, already listening: the Server spawns the
+// gateway, and advertises UDP to the clients, only if it could bind it.
+func NewUDPGateway(conn *net.UDPConn) actor.Producer {
+	return func() actor.Receiver {
+		return &UDPGateway{
+			conn:     conn,
+			sessions: make(map[string]*actor.PID),
+		}
+	}
+}
+
+// listenUDP binds the UDP socket of the gateway.
+func listenUDP(address string) (*net.UDPConn, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	return net.ListenUDP("udp", addr)
+}
+
+// Registrazione del token di una sessione, mandata dopo il welcome
+type UDPRegister struct {
+	Token   string
+	Session *actor.PID
+}
+
+type UDPUnregister struct {
+	Token string
+}
+
+// Datagramma letto dal socket, consegnato al gateway stesso
+type udpPacket struct {
+	Addr *net.UDPAddr
+	Data []byte
+}
+
+// udpFrame is a datagram for a session, token already stripped. Conn is
+// where the session writes its own datagrams back.
+type udpFrame struct {
+	Conn *net.UDPConn
+	Addr *net.UDPAddr
+	Data []byte
+}
+
+func (g *UDPGateway) Receive(c *actor.Context) {
+	switch msg := c.Message().(type) {
+	case actor.Started:
+		g.pid = c.PID()
+		log.Println("Server UDP in ascolto su", g.conn.LocalAddr())
+		go g.readLoop(c)
+
+	case actor.Stopped:
+		g.conn.Close()
+
+	case *UDPRegister:
+		g.sessions[msg.Token] = msg.Session
+
+	case *UDPUnregister:
+		delete(g.sessions, msg.Token)
+
+	case *udpPacket:
+		if len(msg.Data) <= udpTokenSize {
+			return
+		}
+		session, ok := g.sessions[string(msg.Data[:udpTokenSize])]
+		if !ok {
+			return
+		}
+		c.Send(session, &udpFrame{Conn: g.conn, Addr: msg.Addr, Data: msg.Data[udpTokenSize:]})
+	}
+}
+
+func (g *UDPGateway) readLoop(c *actor.Context) {
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := g.conn.ReadFromUDP(buf)
+		if err != nil {
+			log.Println("UDP read error:", err)
+			return
+		}
+		data := make([]byte, n)
+		copy(data, buf[:n])
+		c.Engine().Send(g.pid, &udpPacket{Addr: addr, Data: data})
+	}
+}
+
+// newUDPToken returns a random session token, as raw bytes.
+func newUDPToken() string {
+	token := make([]byte, udpTokenSize)
+	if _, err := rand.Read(token); err != nil {
+		panic(err)
+	}
+	return string(token)
+}
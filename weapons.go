@@ -23,6 +23,10 @@ type Weapon struct {
 	ReloadTime   time.Duration
 	Spread       float64 // Dispersione colpi
 	Price        int     // Per sistema economico
+
+	// Solo per esplosivi
+	BlastRadius float64
+	Fuse        time.Duration
 }
 
 var WeaponStats = map[WeaponType]*Weapon{
@@ -65,15 +69,29 @@ var WeaponStats = map[WeaponType]*Weapon{
 		ReloadTime:   time.Second * 4,
 		Spread:       0.0,
 		Price:        600,
+		BlastRadius:  8.0,
+		Fuse:         time.Millisecond * 2500,
 	},
 }
 
+// La dinamite non occupa uno slot: si lancia con "throw_dynamite" tenendo
+// in mano qualsiasi arma, finché restano candelotti. Ogni modo decide come
+// si ottengono:
+//   - Search & Destroy: si comprano nel buy time, fino a maxDynamite
+//   - Team Deathmatch e Gold Rush: spawnDynamite a ogni spawn
+//   - Duel e Bounty Hunter: niente dinamite
+const (
+	maxDynamite   = 2
+	spawnDynamite = 1
+)
+
 type PlayerWeapons struct {
 	Primary       WeaponType
 	Secondary     WeaponType
 	Current       WeaponType
 	PrimaryAmmo   int
 	SecondaryAmmo int
+	Dynamite      int // candelotti in tasca
 	Money         int
 }
 
@@ -139,10 +157,16 @@ func (pw *PlayerWeapons) BuyWeapon(weaponType WeaponType) bool {
 		return false
 	}
 
+	if weaponType == WeaponDynamite && pw.Dynamite >= maxDynamite {
+		return false
+	}
+
 	pw.Money -= weapon.Price
 
-	// Determina se è primaria o secondaria
-	if weaponType == WeaponRevolver {
+	// Determina se è primaria, secondaria o un candelotto in più
+	if weaponType == WeaponDynamite {
+		pw.Dynamite++
+	} else if weaponType == WeaponRevolver {
 		pw.Secondary = weaponType
 		pw.SecondaryAmmo = weapon.AmmoCapacity
 	} else {
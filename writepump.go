@@ -0,0 +1,119 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Scrittura sul websocket e keepalive
+const (
+	writeWait  = 10 * time.Second
+	pongWait   = 60 * time.Second
+	pingPeriod = pongWait * 9 / 10
+
+	// Messaggi affidabili in attesa oltre i quali il client è troppo lento
+	sendQueueSize = 256
+)
+
+type outFrame struct {
+	frameType int
+	data      []byte
+}
+
+// writePump is the only writer of a websocket connection. The session
+// enqueues frames without blocking; a goroutine writes them with a
+// deadline and pings the client. Reliable frames are queued in order and
+// overflowing the queue drops the client, unreliable ones (snapshots) are
+// coalesced: only the latest one waiting is written, the older are stale.
+type writePump struct {
+	conn  *websocket.Conn
+	queue chan outFrame
+
+	mu        sync.Mutex
+	latest    *outFrame
+	hasLatest chan struct{}
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newWritePump(conn *websocket.Conn) *writePump {
+	return &writePump{
+		conn:      conn,
+		queue:     make(chan outFrame, sendQueueSize),
+		hasLatest: make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+}
+
+// send queues a reliable frame. When the client cannot keep up the
+// connection is closed, and the read loop ends the session.
+func (p *writePump) send(frameType int, data []byte) {
+	select {
+	case p.queue <- outFrame{frameType, data}:
+	case <-p.done:
+	default:
+		log.Println("Coda di invio piena, client disconnesso")
+		p.close()
+	}
+}
+
+// sendUnreliable replaces the unreliable frame waiting to be written, if
+// any, with data.
+func (p *writePump) sendUnreliable(frameType int, data []byte) {
+	p.mu.Lock()
+	p.latest = &outFrame{frameType, data}
+	p.mu.Unlock()
+
+	select {
+	case p.hasLatest <- struct{}{}:
+	default:
+	}
+}
+
+func (p *writePump) close() {
+	p.closeOnce.Do(func() {
+		close(p.done)
+		p.conn.Close()
+	})
+}
+
+func (p *writePump) run() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		var frame outFrame
+		select {
+		case frame = <-p.queue:
+		case <-p.hasLatest:
+			p.mu.Lock()
+			latest := p.latest
+			p.latest = nil
+			p.mu.Unlock()
+			if latest == nil {
+				continue // già scritto col segnale precedente
+			}
+			frame = *latest
+		case <-ticker.C:
+			if err := p.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				log.Println("WS ping error:", err)
+				p.close()
+				return
+			}
+			continue
+		case <-p.done:
+			return
+		}
+
+		p.conn.SetWriteDeadline(time.Now().Add(writeWait))
+		if err := p.conn.WriteMessage(frame.frameType, frame.data); err != nil {
+			log.Println("WS write error:", err)
+			p.close()
+			return
+		}
+	}
+}